@@ -6,6 +6,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -13,6 +16,8 @@ import (
 	"github.com/schattenbrot/mini-blog-api/controllers"
 	"github.com/schattenbrot/mini-blog-api/middlewares"
 	"github.com/schattenbrot/mini-blog-api/routes"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"github.com/schattenbrot/mini-blog-api/workers"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -29,6 +34,7 @@ func main() {
 
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 	validator := validator.New()
+	utils.RegisterValidations(validator, cfg.Roles)
 
 	app := &config.AppConfig{
 		Version:         "1.0.0",
@@ -44,10 +50,21 @@ func main() {
 	db := openDB()
 
 	repo := controllers.NewMongoDBRepo(app, db)
+	err := repo.DB.EnsureIndexes()
+	if err != nil {
+		logger.Fatal(err)
+	}
 	controllers.NewHandlers(repo)
 	middlewareRepo := middlewares.NewMongoDBRepo(app, db)
 	middlewares.NewRouter(middlewareRepo)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workerGroup sync.WaitGroup
+	startWorker(&workerGroup, func() { workers.RunTrashPurge(ctx, app, repo.DB) })
+	startWorker(&workerGroup, func() { workers.RunScheduler(ctx, app, repo.DB) })
+
 	serve := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      routes.Routes(app.Config.Cors),
@@ -56,12 +73,44 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// ListenAndServe returns as soon as the shutdown starts, closed once the
+	// requests in flight are done
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		<-ctx.Done()
+		logger.Println("Shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err := serve.Shutdown(shutdownCtx)
+		if err != nil {
+			logger.Println(err)
+		}
+	}()
+
 	logger.Println(fmt.Sprintf("Starting server on port %d", cfg.Port))
 
-	err := serve.ListenAndServe()
-	if err != nil {
+	err = serve.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		logger.Fatal("Welp ... uwuff")
 	}
+
+	// the workers stop with the context, wait for their last run to finish
+	stop()
+	<-shutdownDone
+	workerGroup.Wait()
+}
+
+// startWorker runs the worker in its own goroutine and tracks it in the group.
+func startWorker(group *sync.WaitGroup, worker func()) {
+	group.Add(1)
+	go func() {
+		defer group.Done()
+		worker()
+	}()
 }
 
 // openDB creates a new database connection and returns the Database
@@ -16,10 +16,51 @@ type Config struct {
 		Name     string
 		SameSite string
 	}
+	Session struct {
+		AccessTTL  time.Duration
+		RefreshTTL time.Duration
+	}
 	DB struct {
 		DSN string
 	}
-	JWT []byte
+	JWT  []byte
+	Site struct {
+		URL string
+	}
+	Feed struct {
+		Title string
+		Size  int
+	}
+	Media struct {
+		Storage         string
+		Dir             string
+		MaxSize         int64
+		ThumbnailWidths []int
+	}
+	UserDeletion struct {
+		Policies []string
+	}
+	Scheduler struct {
+		Interval time.Duration
+	}
+	Roles map[string][]string
+	Mail  struct {
+		Transport string
+		From      string
+		Dir       string
+		SMTP      struct {
+			Host     string
+			Port     int
+			Username string
+			Password string
+		}
+	}
+	EmailVerification string
+	OIDC              map[string]OIDCProvider
+	Trash             struct {
+		Retention     time.Duration
+		PurgeInterval time.Duration
+	}
 }
 
 // AppConfig represents the shared application configuration.
@@ -30,3 +71,12 @@ type AppConfig struct {
 	Logger          *log.Logger
 	Validator       *validator.Validate
 }
+
+// OIDCProvider represents the configuration of an OpenID Connect provider
+// users can log in with.
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
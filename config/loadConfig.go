@@ -4,7 +4,9 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/schattenbrot/mini-blog-api/models"
 	"github.com/spf13/viper"
 )
 
@@ -71,4 +73,225 @@ func LoadConfig(cfg *Config) {
 		log.Println("could not find cookie same site. Defaulting to 'lax'")
 	}
 	cfg.Cookie.SameSite = cookieSameSite
+
+	accessTokenTTLString, ok := viper.Get("ACCESS_TOKEN_TTL").(string)
+	if !ok {
+		accessTokenTTLString = "15m"
+		log.Println("could not find access token ttl. Defaulting to '15m'")
+	}
+	accessTokenTTL, err := time.ParseDuration(accessTokenTTLString)
+	if err != nil || accessTokenTTL <= 0 {
+		accessTokenTTL = 15 * time.Minute
+		log.Println("could not convert access token ttl to a positive duration. Defaulting to '15m'")
+	}
+	cfg.Session.AccessTTL = accessTokenTTL
+
+	refreshTokenTTLString, ok := viper.Get("REFRESH_TOKEN_TTL").(string)
+	if !ok {
+		refreshTokenTTLString = "720h"
+		log.Println("could not find refresh token ttl. Defaulting to '720h'")
+	}
+	refreshTokenTTL, err := time.ParseDuration(refreshTokenTTLString)
+	if err != nil || refreshTokenTTL <= 0 {
+		refreshTokenTTL = 720 * time.Hour
+		log.Println("could not convert refresh token ttl to a positive duration. Defaulting to '720h'")
+	}
+	cfg.Session.RefreshTTL = refreshTokenTTL
+
+	siteURL, ok := viper.Get("SITE_URL").(string)
+	if !ok {
+		siteURL = "http://localhost:4000"
+		log.Println("could not find site url. Defaulting to 'http://localhost:4000'")
+	}
+	cfg.Site.URL = strings.TrimRight(siteURL, "/")
+
+	feedTitle, ok := viper.Get("FEED_TITLE").(string)
+	if !ok {
+		feedTitle = "Mini Blog"
+		log.Println("could not find feed title. Defaulting to 'Mini Blog'")
+	}
+	cfg.Feed.Title = feedTitle
+
+	feedSizeString, ok := viper.Get("FEED_SIZE").(string)
+	if !ok {
+		feedSizeString = "20"
+		log.Println("could not find feed size. Defaulting to 20")
+	}
+	feedSize, err := strconv.Atoi(feedSizeString)
+	if err != nil || feedSize < 1 {
+		feedSize = 20
+		log.Println("could not convert feed size to a positive int. Defaulting to 20")
+	}
+	cfg.Feed.Size = feedSize
+
+	mediaStorage, ok := viper.Get("MEDIA_STORAGE").(string)
+	if !ok || (mediaStorage != "local" && mediaStorage != "gridfs") {
+		mediaStorage = "local"
+		log.Println("could not find media storage. Defaulting to 'local'")
+	}
+	cfg.Media.Storage = mediaStorage
+
+	mediaDir, ok := viper.Get("MEDIA_DIR").(string)
+	if !ok {
+		mediaDir = "media"
+		log.Println("could not find media dir. Defaulting to 'media'")
+	}
+	cfg.Media.Dir = mediaDir
+
+	mediaMaxSizeString, ok := viper.Get("MEDIA_MAX_SIZE").(string)
+	if !ok {
+		mediaMaxSizeString = "5242880"
+		log.Println("could not find media max size. Defaulting to 5242880 bytes")
+	}
+	mediaMaxSize, err := strconv.ParseInt(mediaMaxSizeString, 10, 64)
+	if err != nil || mediaMaxSize < 1 {
+		mediaMaxSize = 5242880
+		log.Println("could not convert media max size to a positive int. Defaulting to 5242880 bytes")
+	}
+	cfg.Media.MaxSize = mediaMaxSize
+
+	thumbnailWidthsString, ok := viper.Get("MEDIA_THUMBNAIL_WIDTHS").(string)
+	if !ok {
+		thumbnailWidthsString = "150 600"
+		log.Println("could not find media thumbnail widths. Defaulting to '150 600'")
+	}
+	cfg.Media.ThumbnailWidths = []int{}
+	for _, widthString := range strings.Fields(thumbnailWidthsString) {
+		width, err := strconv.Atoi(widthString)
+		if err != nil || width < 1 {
+			log.Println("could not convert media thumbnail width", widthString, "to a positive int. Skipping it")
+			continue
+		}
+		cfg.Media.ThumbnailWidths = append(cfg.Media.ThumbnailWidths, width)
+	}
+
+	userDeletionPoliciesString, ok := viper.Get("USER_DELETION_POLICIES").(string)
+	if !ok {
+		userDeletionPoliciesString = models.UserDeletionRefuse
+		log.Println("could not find user deletion policies. Defaulting to 'refuse'")
+	}
+	cfg.UserDeletion.Policies = []string{}
+	for _, policy := range strings.Fields(userDeletionPoliciesString) {
+		if policy != models.UserDeletionRefuse && policy != models.UserDeletionGhost && policy != models.UserDeletionDelete {
+			log.Println("unknown user deletion policy", policy+". Skipping it")
+			continue
+		}
+		cfg.UserDeletion.Policies = append(cfg.UserDeletion.Policies, policy)
+	}
+	if len(cfg.UserDeletion.Policies) == 0 {
+		cfg.UserDeletion.Policies = []string{models.UserDeletionRefuse}
+		log.Println("could not find a valid user deletion policy. Defaulting to 'refuse'")
+	}
+
+	rolesFile, ok := viper.Get("ROLES_FILE").(string)
+	if !ok {
+		rolesFile = ""
+		log.Println("could not find roles file. Defaulting to the built-in roles")
+	}
+	cfg.Roles = loadRoles(rolesFile)
+
+	mailTransport, ok := viper.Get("MAIL_TRANSPORT").(string)
+	if !ok || (mailTransport != "smtp" && mailTransport != "file") {
+		mailTransport = "file"
+		log.Println("could not find mail transport. Defaulting to 'file'")
+	}
+	cfg.Mail.Transport = mailTransport
+
+	mailFrom, ok := viper.Get("MAIL_FROM").(string)
+	if !ok {
+		mailFrom = "no-reply@localhost"
+		log.Println("could not find mail from. Defaulting to 'no-reply@localhost'")
+	}
+	cfg.Mail.From = mailFrom
+
+	mailDir, ok := viper.Get("MAIL_DIR").(string)
+	if !ok {
+		mailDir = ""
+		log.Println("could not find mail dir. Defaulting to writing mails to the log")
+	}
+	cfg.Mail.Dir = mailDir
+
+	smtpHost, ok := viper.Get("SMTP_HOST").(string)
+	if !ok {
+		smtpHost = "localhost"
+		log.Println("could not find smtp host. Defaulting to 'localhost'")
+	}
+	cfg.Mail.SMTP.Host = smtpHost
+
+	smtpPortString, ok := viper.Get("SMTP_PORT").(string)
+	if !ok {
+		smtpPortString = "587"
+		log.Println("could not find smtp port. Defaulting to 587")
+	}
+	smtpPort, err := strconv.Atoi(smtpPortString)
+	if err != nil {
+		smtpPort = 587
+		log.Println("could not convert smtp port to int. Defaulting to 587")
+	}
+	cfg.Mail.SMTP.Port = smtpPort
+
+	smtpUsername, ok := viper.Get("SMTP_USERNAME").(string)
+	if !ok {
+		smtpUsername = ""
+		log.Println("could not find smtp username. Defaulting to no authentication")
+	}
+	cfg.Mail.SMTP.Username = smtpUsername
+
+	smtpPassword, ok := viper.Get("SMTP_PASSWORD").(string)
+	if !ok {
+		smtpPassword = ""
+	}
+	cfg.Mail.SMTP.Password = smtpPassword
+
+	emailVerification, ok := viper.Get("EMAIL_VERIFICATION").(string)
+	if !ok || (emailVerification != models.EmailVerificationOff &&
+		emailVerification != models.EmailVerificationLimit &&
+		emailVerification != models.EmailVerificationRefuse) {
+		emailVerification = models.EmailVerificationLimit
+		log.Println("could not find email verification. Defaulting to 'limit'")
+	}
+	cfg.EmailVerification = emailVerification
+
+	oidcProviders, ok := viper.Get("OIDC_PROVIDERS").(string)
+	if !ok {
+		oidcProviders = ""
+		log.Println("could not find oidc providers. Defaulting to no login providers")
+	}
+	cfg.OIDC = loadOIDCProviders(oidcProviders)
+
+	schedulerIntervalString, ok := viper.Get("SCHEDULER_INTERVAL").(string)
+	if !ok {
+		schedulerIntervalString = "1m"
+		log.Println("could not find scheduler interval. Defaulting to '1m'")
+	}
+	schedulerInterval, err := time.ParseDuration(schedulerIntervalString)
+	if err != nil || schedulerInterval <= 0 {
+		schedulerInterval = time.Minute
+		log.Println("could not convert scheduler interval to a positive duration. Defaulting to '1m'")
+	}
+	cfg.Scheduler.Interval = schedulerInterval
+
+	trashRetentionString, ok := viper.Get("TRASH_RETENTION").(string)
+	if !ok {
+		trashRetentionString = "720h"
+		log.Println("could not find trash retention. Defaulting to '720h'")
+	}
+	trashRetention, err := time.ParseDuration(trashRetentionString)
+	if err != nil || trashRetention <= 0 {
+		trashRetention = 720 * time.Hour
+		log.Println("could not convert trash retention to a positive duration. Defaulting to '720h'")
+	}
+	cfg.Trash.Retention = trashRetention
+
+	trashPurgeIntervalString, ok := viper.Get("TRASH_PURGE_INTERVAL").(string)
+	if !ok {
+		trashPurgeIntervalString = "1h"
+		log.Println("could not find trash purge interval. Defaulting to '1h'")
+	}
+	trashPurgeInterval, err := time.ParseDuration(trashPurgeIntervalString)
+	if err != nil || trashPurgeInterval <= 0 {
+		trashPurgeInterval = time.Hour
+		log.Println("could not convert trash purge interval to a positive duration. Defaulting to '1h'")
+	}
+	cfg.Trash.PurgeInterval = trashPurgeInterval
 }
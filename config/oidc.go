@@ -0,0 +1,53 @@
+package config
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// oidcProviderName restricts provider names to what can be part of variable
+// names and URLs.
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// loadOIDCProviders reads the configuration of the space separated provider
+// names from the variables OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET and OIDC_<NAME>_SCOPES. Providers without issuer
+// or client ID are skipped.
+// Returns the providers by their name.
+func loadOIDCProviders(names string) map[string]OIDCProvider {
+	providers := map[string]OIDCProvider{}
+
+	for _, name := range strings.Fields(strings.ToLower(names)) {
+		if !oidcProviderName.MatchString(name) {
+			log.Println("oidc provider name", name, "may only contain letters and digits. Skipping it")
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		issuer, _ := viper.Get(prefix + "ISSUER").(string)
+		clientID, _ := viper.Get(prefix + "CLIENT_ID").(string)
+		clientSecret, _ := viper.Get(prefix + "CLIENT_SECRET").(string)
+		scopes, ok := viper.Get(prefix + "SCOPES").(string)
+		if !ok {
+			scopes = "openid email profile"
+		}
+
+		if issuer == "" || clientID == "" {
+			log.Println("could not find issuer or client id of oidc provider", name+". Skipping it")
+			continue
+		}
+
+		providers[name] = OIDCProvider{
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       strings.Fields(scopes),
+		}
+	}
+
+	return providers
+}
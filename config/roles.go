@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+// defaultRoles returns the built-in roles and their permissions. Users may
+// publish their posts like before the roles existed. The author role grants
+// the same, so a roles file can take publishing away from the user role
+// without touching authors.
+func defaultRoles() map[string][]string {
+	author := []string{
+		models.PermPostCreate,
+		models.PermPostPublish,
+		models.PermCommentCreate,
+		models.PermMediaUpload,
+		models.PermSeriesCreate,
+	}
+	user := append([]string{}, author...)
+
+	return map[string][]string{
+		models.RoleUser:   user,
+		models.RoleAuthor: author,
+		models.RoleEditor: append(append([]string{}, author...),
+			models.PermPostReadAny,
+			models.PermPostEditAny,
+			models.PermPostDeleteAny,
+			models.PermMediaDeleteAny,
+			models.PermSeriesEditAny,
+		),
+		models.RoleModerator: append(append([]string{}, user...),
+			models.PermCommentEditAny,
+			models.PermCommentDeleteAny,
+			models.PermUserList,
+			models.PermUserReadPrivate,
+			models.PermUserBan,
+		),
+		models.RoleAdmin:      {models.PermAll},
+		models.RoleUnverified: {},
+	}
+}
+
+// loadRoles reads the role definitions from the given JSON file, mapping role
+// names to lists of permissions. Roles from the file replace built-in roles of
+// the same name. Unknown permissions are skipped.
+// Returns the role definitions.
+func loadRoles(path string) map[string][]string {
+	roles := defaultRoles()
+	if path == "" {
+		return roles
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Println("could not read roles file", path+". Using the built-in roles only")
+		return roles
+	}
+
+	var fileRoles map[string][]string
+	err = json.Unmarshal(data, &fileRoles)
+	if err != nil {
+		log.Println("could not parse roles file", path+". Using the built-in roles only")
+		return roles
+	}
+
+	for role, permissions := range fileRoles {
+		roles[role] = []string{}
+		for _, permission := range permissions {
+			if !models.IsPermission(permission) {
+				log.Println("unknown permission", permission, "of role", role+". Skipping it")
+				continue
+			}
+			roles[role] = append(roles[role], permission)
+		}
+	}
+
+	return roles
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Audit actions recorded for the admin user management.
+const (
+	auditActionSetRoles       = "user.set_roles"
+	auditActionSuspend        = "user.suspend"
+	auditActionUnsuspend      = "user.unsuspend"
+	auditActionRevokeSessions = "user.revoke_sessions"
+)
+
+// AdminGetUsers is the handler for retrieving a paginated list of all users.
+// The users can be searched by name or email with the query parameter "q".
+func (m *Repository) AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := paginationFromQuery(r)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	users, err := m.DB.GetUsers(r.URL.Query().Get("q"), page, limit)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, m.userResponses(r, users...))
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminSetUserRoles is the handler for replacing the roles of a user.
+func (m *Repository) AdminSetUserRoles(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var request models.RolesRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.DB.SetUserRoles(id, request.Roles)
+	if err != nil {
+		m.adminUpdateError(w, err)
+		return
+	}
+
+	m.audit(r, auditActionSetRoles, id, map[string]interface{}{"roles": request.Roles})
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminSuspendUser is the handler for suspending a user with a reason.
+// Suspended users are logged out and cannot log in until the suspension is
+// lifted.
+func (m *Repository) AdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var request models.SuspensionRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	admin, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+	if admin == id {
+		errorJSON(w, errors.New("admins cannot suspend themselves"))
+		return
+	}
+	if !m.outranks(w, r, id) {
+		return
+	}
+
+	suspension := models.Suspension{
+		Reason: request.Reason,
+		By:     admin,
+		At:     time.Now(),
+	}
+
+	err = m.DB.SuspendUser(id, suspension)
+	if err != nil {
+		m.adminUpdateError(w, err)
+		return
+	}
+
+	m.audit(r, auditActionSuspend, id, map[string]interface{}{"reason": request.Reason})
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminUnsuspendUser is the handler for lifting the suspension of a user.
+func (m *Repository) AdminUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if !m.outranks(w, r, id) {
+		return
+	}
+
+	err := m.DB.UnsuspendUser(id)
+	if err != nil {
+		m.adminUpdateError(w, err)
+		return
+	}
+
+	m.audit(r, auditActionUnsuspend, id, nil)
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminLogoutUser is the handler for logging a user out of all its sessions.
+func (m *Repository) AdminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if !m.outranks(w, r, id) {
+		return
+	}
+
+	err := m.DB.RevokeSessions(id)
+	if err != nil {
+		m.adminUpdateError(w, err)
+		return
+	}
+
+	m.audit(r, auditActionRevokeSessions, id, nil)
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminGetAuditLog is the handler for retrieving a paginated list of the
+// recorded admin actions.
+func (m *Repository) AdminGetAuditLog(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := paginationFromQuery(r)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	entries, err := m.DB.GetAuditEntries(page, limit)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, entries)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// adminUpdateError writes the response for an error of an update of a user.
+func (m *Repository) adminUpdateError(w http.ResponseWriter, err error) {
+	if err.Error() == "the provided hex string is not a valid ObjectID" {
+		errorJSON(w, err)
+		return
+	}
+	if err.Error() == dbrepo.ErrorDocumentNotFound {
+		errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	errorJSON(w, err, http.StatusInternalServerError)
+}
+
+// outranks checks if the roles of the requesting user grant every permission
+// of the roles of the target user, so nobody can act against users with more
+// rights. Writes the error response if not.
+func (m *Repository) outranks(w http.ResponseWriter, r *http.Request, target string) bool {
+	auth, ok := utils.GetAuthentication(r)
+	if !ok {
+		errorJSON(w, errors.New("not authenticated"), http.StatusUnauthorized)
+		return false
+	}
+
+	user, err := m.DB.GetUserById(target)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+			return false
+		}
+		m.adminUpdateError(w, err)
+		return false
+	}
+
+	roles, err := m.DB.GetUserRoles(auth.UserID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return false
+	}
+
+	if !utils.GrantsAllOf(m.App.Config.Roles, roles, user.Roles) {
+		errorJSON(w, errors.New("the user has permissions you don't have"), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// audit records an action of the logged in admin on the target. A failed
+// recording is logged since the action itself already happened.
+func (m *Repository) audit(r *http.Request, action, target string, details map[string]interface{}) {
+	actor, _ := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+
+	entry := models.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+
+	err := m.DB.InsertAuditEntry(entry)
+	if err != nil {
+		m.App.Logger.Println("could not record audit entry", action, "on", target+":", err)
+	}
+}
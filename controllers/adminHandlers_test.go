@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// adminTestDB knows the roles of the users. The other methods are the ones of
+// the testing repository.
+type adminTestDB struct {
+	database.DatabaseRepo
+
+	roles map[string][]string
+}
+
+func (db *adminTestDB) GetUserById(id string) (*models.User, error) {
+	roles, ok := db.roles[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &models.User{ID: id, Roles: roles}, nil
+}
+
+func (db *adminTestDB) GetUserRoles(id string) ([]string, error) {
+	return db.roles[id], nil
+}
+
+func TestOutranks(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller string
+		target string
+		status int
+	}{
+		{"moderator against user", "moderator", "user", 0},
+		{"moderator against moderator", "moderator", "other-moderator", 0},
+		{"moderator against admin", "moderator", "admin", http.StatusForbidden},
+		{"moderator against editor", "moderator", "editor", http.StatusForbidden},
+		{"admin against moderator", "admin", "moderator", 0},
+		{"admin against admin", "admin", "other-admin", 0},
+		{"unknown user", "admin", "nobody", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &config.AppConfig{Logger: log.New(io.Discard, "", 0)}
+			app.Config.Roles = map[string][]string{
+				models.RoleUser:      {models.PermPostCreate},
+				models.RoleEditor:    {models.PermPostCreate, models.PermPostEditAny},
+				models.RoleModerator: {models.PermPostCreate, models.PermUserBan},
+				models.RoleAdmin:     {models.PermAll},
+			}
+
+			db := &adminTestDB{
+				DatabaseRepo: dbrepo.NewTestingRepo(app),
+				roles: map[string][]string{
+					"user":            {models.RoleUser},
+					"editor":          {models.RoleEditor},
+					"moderator":       {models.RoleUser, models.RoleModerator},
+					"other-moderator": {models.RoleModerator},
+					"admin":           {models.RoleAdmin},
+					"other-admin":     {models.RoleAdmin},
+				},
+			}
+			m := &Repository{App: app, DB: db}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/admin/users/"+tt.target+"/logout", nil)
+			r = utils.WithAuthentication(r, utils.Authentication{UserID: tt.caller})
+			w := httptest.NewRecorder()
+
+			ok := m.outranks(w, r, tt.target)
+			if ok != (tt.status == 0) || (!ok && w.Code != tt.status) {
+				t.Errorf("outranks = %t with status %d, want status %d", ok, w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+)
+
+// apiKeyHintLength is how many characters of a key after its prefix are kept
+// to recognize it.
+const apiKeyHintLength = 6
+
+// CreateAPIKey is the handler for creating a personal API key of the current
+// user. The key is only part of this response, afterwards only its hash is
+// known.
+func (m *Repository) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	var request models.APIKeyRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	now := time.Now()
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		err = errors.New("expires_at has to be in the future")
+		errorJSON(w, err)
+		return
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	secret := models.APIKeyPrefix + token
+
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      request.Name,
+		Hint:      secret[:len(models.APIKeyPrefix)+apiKeyHintLength],
+		Scopes:    request.Scopes,
+		CreatedAt: now,
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	key.ID, err = m.DB.InsertAPIKey(key, utils.HashToken(secret))
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	key.Key = secret
+
+	err = writeJSON(w, http.StatusCreated, key)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetAPIKeys is the handler for listing the unexpired personal API keys of
+// the current user.
+func (m *Repository) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := m.DB.GetAPIKeys(userID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, keys)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// DeleteAPIKey is the handler for revoking a personal API key of the current
+// user.
+func (m *Repository) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID := chi.URLParam(r, "keyID")
+
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	err = m.DB.DeleteAPIKey(userID, keyID)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
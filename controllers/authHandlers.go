@@ -2,22 +2,55 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// LoginUser is the type for authentication-request bodies
+// LoginUser is the type for authentication-request bodies. Clients which
+// can't use cookies set ReturnToken to get the tokens in the response.
 type LoginUser struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=24"`
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required,min=8,max=24"`
+	ReturnToken bool   `json:"return_token"`
+}
+
+// RefreshRequest is the type for refresh-request bodies of clients which
+// don't use cookies.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// sessionTokens are the tokens of a session handed to the client.
+type sessionTokens struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// sessionResponse is the response of a successful login or refresh. The
+// tokens are only part of it if the client asked for them.
+type sessionResponse struct {
+	OK bool   `json:"ok"`
+	ID string `json:"id"`
+	*sessionTokens
 }
 
 // Login is the handler for logging a user in with the given email and password.
-// Sets a cookie if successful or an error message.
+// Sets the session cookies, or returns the tokens if asked for, if successful
+// or an error message. Users with two-factor authentication get a challenge
+// token for the second login step instead.
 func (m *Repository) Login(w http.ResponseWriter, r *http.Request) {
 	var loginUser LoginUser
 	err := json.NewDecoder(r.Body).Decode(&loginUser)
@@ -48,24 +81,219 @@ func (m *Repository) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = m.canLogIn(user)
+	if err != nil {
+		errorJSON(w, err, http.StatusForbidden)
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		m.startTwoFactorChallenge(w, user)
+		return
+	}
+
+	tokens, err := m.startSession(r, user.ID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.writeSession(w, user.ID, tokens, loginUser.ReturnToken)
+}
+
+// Refresh is the handler for exchanging the refresh token of a session for a
+// new access token. The refresh token is rotated on every use and reusing an
+// old one ends the session. A refresh token sent in the body is answered
+// with the new tokens instead of cookies.
+func (m *Repository) Refresh(w http.ResponseWriter, r *http.Request) {
+	var request RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil && err != io.EOF {
+		errorJSON(w, err)
+		return
+	}
+
+	returnToken := request.RefreshToken != ""
+	if !returnToken {
+		cookie, err := r.Cookie(m.refreshCookieName())
+		if err != nil {
+			errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		request.RefreshToken = cookie.Value
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	session, err := m.DB.RotateSession(utils.HashToken(request.RefreshToken), utils.HashToken(refreshToken), r.UserAgent(), clientIP(r))
+	if err != nil {
+		if err.Error() == dbrepo.ErrorRefreshTokenReused {
+			m.App.Logger.Println("reused refresh token, the session was revoked")
+		}
+		if err.Error() == dbrepo.ErrorRefreshTokenReused || err.Error() == dbrepo.ErrorInvalidToken {
+			m.clearSessionCookies(w)
+			errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	user, err := m.DB.GetUserById(session.UserID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			m.clearSessionCookies(w)
+			errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.canLogIn(user)
+	if err != nil {
+		m.clearSessionCookies(w)
+		errorJSON(w, err, http.StatusForbidden)
+		return
+	}
+
+	tokens, err := m.newSessionTokens(user.ID, session.ID, refreshToken, session.ExpiresAt)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.writeSession(w, user.ID, tokens, returnToken)
+}
+
+// Logout ends the current session of the user.
+func (m *Repository) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, err := utils.GetClaims(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	err = m.DB.RevokeSession(claims.Issuer, claims.Id)
+	if err != nil && err.Error() != dbrepo.ErrorDocumentNotFound {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.clearSessionCookies(w)
+
+	writeJSON(w, http.StatusNoContent)
+}
+
+// canLogIn checks if the user is allowed to start or continue a session.
+// Returns an error describing why not if it isn't.
+func (m *Repository) canLogIn(user *models.User) error {
+	if !user.EmailVerified() && m.App.Config.EmailVerification == models.EmailVerificationRefuse {
+		return errors.New("email is not verified")
+	}
+
+	if user.Suspension != nil {
+		return fmt.Errorf("user is suspended: %s", user.Suspension.Reason)
+	}
+
+	return nil
+}
+
+// refreshCookiePath limits the refresh token cookie to the user routes, so it
+// is not sent along with every request.
+const refreshCookiePath = "/v1/users"
+
+// refreshCookieName is the name of the cookie holding the refresh token.
+func (m *Repository) refreshCookieName() string {
+	return m.App.Config.Cookie.Name + "-refresh"
+}
+
+// startSession stores a new session of the user for the device of the request.
+// Returns the tokens of the session and an error if any occurred.
+func (m *Repository) startSession(r *http.Request, userID string) (*sessionTokens, error) {
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	session := models.Session{
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.App.Config.Session.RefreshTTL),
+	}
+
+	sessionID, err := m.DB.InsertSession(session, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.newSessionTokens(userID, sessionID, refreshToken, session.ExpiresAt)
+}
+
+// newSessionTokens creates a new short-lived access token of the session and
+// bundles it with the refresh token.
+// Returns the tokens and an error if any occurred.
+func (m *Repository) newSessionTokens(userID, sessionID, refreshToken string, refreshExpiresAt time.Time) (*sessionTokens, error) {
 	currTime := time.Now()
+	expiresAt := currTime.Add(m.App.Config.Session.AccessTTL)
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		Issuer:    user.ID,
-		ExpiresAt: currTime.Add(time.Hour * 24).Unix(),
+		Id:        sessionID,
+		Issuer:    userID,
+		IssuedAt:  currTime.Unix(),
+		ExpiresAt: expiresAt.Unix(),
 	})
 
 	tokenString, err := token.SignedString(m.App.Config.JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionTokens{
+		AccessToken:      tokenString,
+		TokenType:        "Bearer",
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// writeSession hands the tokens of a session to the client, either in the
+// response body or as cookies.
+func (m *Repository) writeSession(w http.ResponseWriter, userID string, tokens *sessionTokens, returnToken bool) {
+	response := sessionResponse{
+		OK: true,
+		ID: userID,
+	}
+
+	if returnToken {
+		response.sessionTokens = tokens
+	} else {
+		m.setCookie(w, m.App.Config.Cookie.Name, tokens.AccessToken, "/", tokens.ExpiresAt)
+		m.setCookie(w, m.refreshCookieName(), tokens.RefreshToken, refreshCookiePath, tokens.RefreshExpiresAt)
+	}
+
+	err := writeJSON(w, http.StatusOK, response)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
-		return
 	}
+}
 
+// setCookie sets an http only cookie with the configured same site attribute.
+func (m *Repository) setCookie(w http.ResponseWriter, name, value, path string, expires time.Time) {
 	cookie := &http.Cookie{
-		Name:     m.App.Config.Cookie.Name,
-		Path:     "/",
-		Value:    tokenString,
-		Expires:  currTime.Add(time.Hour * 24),
+		Name:     name,
+		Path:     path,
+		Value:    value,
+		Expires:  expires,
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	}
@@ -76,29 +304,12 @@ func (m *Repository) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.SetCookie(w, cookie)
-
-	type jsonResp struct {
-		OK bool   `json:"ok"`
-		ID string `json:"id"`
-	}
-
-	writeJSON(w, http.StatusOK, jsonResp{
-		OK: true,
-		ID: user.ID,
-	})
 }
 
-// Logout logs the user out
-func (m *Repository) Logout(w http.ResponseWriter, r *http.Request) {
-	cookie := &http.Cookie{
-		Name:     m.App.Config.Cookie.Name,
-		Path:     "/",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-	}
+// clearSessionCookies removes the access and refresh token cookies.
+func (m *Repository) clearSessionCookies(w http.ResponseWriter) {
+	expired := time.Now().Add(-time.Hour)
 
-	http.SetCookie(w, cookie)
-
-	writeJSON(w, http.StatusNoContent)
+	m.setCookie(w, m.App.Config.Cookie.Name, "", "/", expired)
+	m.setCookie(w, m.refreshCookieName(), "", refreshCookiePath, expired)
 }
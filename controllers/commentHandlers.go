@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InsertComment is the handler for adding a comment or a reply to a post.
+func (m *Repository) InsertComment(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+
+	var comment models.Comment
+	err := json.NewDecoder(r.Body).Decode(&comment)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(comment)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	_, ok := m.getVisiblePost(w, r, postID)
+	if !ok {
+		return
+	}
+
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	comment.PostID = postID
+	comment.Author = userID
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = time.Now()
+
+	id, err := m.DB.InsertComment(comment)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorParentNotFound {
+			errorJSON(w, err)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	type jsonResp struct {
+		OK bool    `json:"ok"`
+		ID *string `json:"id"`
+	}
+
+	response := jsonResp{
+		OK: true,
+		ID: id,
+	}
+
+	err = writeJSON(w, http.StatusCreated, response)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetComments is the handler for retrieving the comments of a post.
+// Replies are nested into their parent comments.
+func (m *Repository) GetComments(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+
+	_, ok := m.getVisiblePost(w, r, postID)
+	if !ok {
+		return
+	}
+
+	comments, err := m.DB.GetCommentsByPost(postID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, buildCommentTree(comments))
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// UpdateCommentById is the handler for updating the text of a comment.
+func (m *Repository) UpdateCommentById(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+	commentID := chi.URLParam(r, "commentID")
+
+	var comment models.Comment
+	err := json.NewDecoder(r.Body).Decode(&comment)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(comment)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	comment.ID = commentID
+	comment.PostID = postID
+
+	err = m.DB.UpdateComment(comment)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// DeleteComment is the handler for deleting a comment and all of its replies.
+func (m *Repository) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+	commentID := chi.URLParam(r, "commentID")
+
+	err := m.DB.DeleteComment(postID, commentID)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// getVisiblePost fetches a post which the requesting user is allowed to see
+// and writes the error response if there is none.
+func (m *Repository) getVisiblePost(w http.ResponseWriter, r *http.Request, id string) (*models.Post, bool) {
+	post, err := m.DB.GetPostById(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+			return nil, false
+		}
+		errorJSON(w, err)
+		return nil, false
+	}
+
+	if !m.canSeePost(r, post) {
+		errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+		return nil, false
+	}
+
+	return post, true
+}
+
+// buildCommentTree nests the replies of a flat list of comments into their
+// parents. The order of the list is kept on every level.
+func buildCommentTree(comments []*models.Comment) []*models.Comment {
+	byID := map[string]*models.Comment{}
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+
+	roots := []*models.Comment{}
+	for _, comment := range comments {
+		parent, ok := byID[comment.ParentID]
+		if comment.ParentID == "" || !ok {
+			roots = append(roots, comment)
+			continue
+		}
+		parent.Replies = append(parent.Replies, comment)
+	}
+
+	return roots
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     atomAuthor     `xml:"author"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    atomText       `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// feedData holds everything needed to render a feed in any format.
+type feedData struct {
+	Title        string
+	Link         string
+	Self         string
+	Posts        []*models.Post
+	Authors      map[string]string
+	LastModified time.Time
+	ETag         string
+}
+
+// RSSFeed is the handler for the RSS 2.0 feed of the newest published posts.
+// The feed can be narrowed down to an author or a tag with the "author" and
+// "tag" query parameters.
+func (m *Repository) RSSFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := m.prepareFeed(w, r, "rss")
+	if !ok {
+		return
+	}
+
+	channel := rssChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Title,
+		Self:        atomLink{Href: feed.Self, Rel: "self", Type: "application/rss+xml"},
+		Items:       []rssItem{},
+	}
+	if !feed.LastModified.IsZero() {
+		channel.LastBuildDate = feed.LastModified.Format(time.RFC1123Z)
+	}
+
+	for _, post := range feed.Posts {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        m.postURL(post),
+			GUID:        rssGUID{Value: post.ID},
+			Description: post.HTML,
+			Creator:     feed.Authors[post.Creator],
+			Categories:  post.Tags,
+			PubDate:     publishedAt(post).Format(time.RFC1123Z),
+		})
+	}
+
+	err := writeXML(w, http.StatusOK, "application/rss+xml; charset=utf-8", rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: channel,
+	})
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AtomFeed is the handler for the Atom feed of the newest published posts.
+// The feed can be narrowed down to an author or a tag with the "author" and
+// "tag" query parameters.
+func (m *Repository) AtomFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := m.prepareFeed(w, r, "atom")
+	if !ok {
+		return
+	}
+
+	updated := feed.LastModified
+	if updated.IsZero() {
+		updated = m.App.ServerStartTime
+	}
+
+	atom := atomFeed{
+		ID:      feed.Self,
+		Title:   feed.Title,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.Link, Rel: "alternate"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	for _, post := range feed.Posts {
+		categories := []atomCategory{}
+		for _, tag := range post.Tags {
+			categories = append(categories, atomCategory{Term: tag})
+		}
+
+		atom.Entries = append(atom.Entries, atomEntry{
+			ID:         m.postURL(post),
+			Title:      post.Title,
+			Updated:    post.UpdatedAt.Format(time.RFC3339),
+			Published:  publishedAt(post).Format(time.RFC3339),
+			Author:     atomAuthor{Name: feed.Authors[post.Creator]},
+			Link:       atomLink{Href: m.postURL(post), Rel: "alternate"},
+			Categories: categories,
+			Summary:    atomText{Type: "html", Value: post.HTML},
+		})
+	}
+
+	err := writeXML(w, http.StatusOK, "application/atom+xml; charset=utf-8", atom)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// prepareFeed fetches the posts of a feed and resolves their authors.
+// It sets the caching headers and answers conditional requests with
+// 304 Not Modified, in which case false is returned.
+func (m *Repository) prepareFeed(w http.ResponseWriter, r *http.Request, format string) (*feedData, bool) {
+	filter := postFilterFromQuery(r)
+	filter.Creator = r.URL.Query().Get("author")
+
+	posts, err := m.DB.GetPostsByPage(1, m.App.Config.Feed.Size, filter)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	err = renderPosts(posts...)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	feed := &feedData{
+		Title:   m.App.Config.Feed.Title,
+		Link:    m.App.Config.Site.URL,
+		Self:    m.App.Config.Site.URL + r.URL.RequestURI(),
+		Posts:   posts,
+		Authors: map[string]string{},
+	}
+
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s|%s", format, r.URL.RawQuery)
+	for _, post := range posts {
+		if post.UpdatedAt.After(feed.LastModified) {
+			feed.LastModified = post.UpdatedAt
+		}
+		fmt.Fprintf(hash, "|%s@%d", post.ID, post.UpdatedAt.UnixNano())
+
+		if _, ok := feed.Authors[post.Creator]; ok {
+			continue
+		}
+		feed.Authors[post.Creator] = ""
+		user, err := m.DB.GetUserById(post.Creator)
+		if err == nil {
+			feed.Authors[post.Creator] = user.Name
+		}
+	}
+	feed.ETag = fmt.Sprintf(`"%x"`, hash.Sum(nil))
+
+	if filter.Creator != "" && feed.Authors[filter.Creator] != "" {
+		feed.Title = fmt.Sprintf("%s - %s", feed.Title, feed.Authors[filter.Creator])
+	}
+	if filter.Tag != "" {
+		feed.Title = fmt.Sprintf("%s - #%s", feed.Title, filter.Tag)
+	}
+
+	w.Header().Set("ETag", feed.ETag)
+	if !feed.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", feed.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, feed.ETag, feed.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil, false
+	}
+
+	return feed, true
+}
+
+// notModified checks the conditional headers of a request against the ETag
+// and the last modification of a resource.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return match == etag || match == "*"
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// postURL returns the public URL of a post, preferring its slug.
+func (m *Repository) postURL(post *models.Post) string {
+	if post.Slug != "" {
+		return m.App.Config.Site.URL + "/v1/posts/by-slug/" + url.PathEscape(post.Slug)
+	}
+	return m.App.Config.Site.URL + "/v1/posts/" + post.ID
+}
+
+// publishedAt returns the time a post was published. Posts from before the
+// post lifecycle fall back to their creation time.
+func publishedAt(post *models.Post) time.Time {
+	if post.PublishAt.IsZero() {
+		return post.CreatedAt
+	}
+	return post.PublishAt
+}
@@ -1,16 +1,25 @@
 package controllers
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/schattenbrot/mini-blog-api/config"
 	"github.com/schattenbrot/mini-blog-api/database"
 	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/mailer"
+	"github.com/schattenbrot/mini-blog-api/oidc"
+	"github.com/schattenbrot/mini-blog-api/storage"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Repository represents the handler repository to share the app configuragion.
 type Repository struct {
-	App *config.AppConfig
-	DB  database.DatabaseRepo
+	App     *config.AppConfig
+	DB      database.DatabaseRepo
+	Storage storage.Storage
+	Mailer  mailer.Mailer
+	OIDC    map[string]*oidc.Provider
 }
 
 // Repo is the repository to share the app configuration.
@@ -18,17 +27,54 @@ var Repo *Repository
 
 // NewTestDBRepo returns a new repository for testing purposes.
 func NewTestDBRepo(a *config.AppConfig) *Repository {
+	store, err := storage.NewLocalStorage(filepath.Join(os.TempDir(), "mini-blog-media"))
+	if err != nil {
+		a.Logger.Fatal(err)
+	}
+
+	mail, err := mailer.NewFileMailer("", a.Logger)
+	if err != nil {
+		a.Logger.Fatal(err)
+	}
+
 	return &Repository{
-		App: a,
-		DB:  dbrepo.NewTestingRepo(a),
+		App:     a,
+		DB:      dbrepo.NewTestingRepo(a),
+		Storage: store,
+		Mailer:  mail,
+		OIDC:    newOIDCProviders(a),
 	}
 }
 
 // NewMongoDBRepo returns a new instance of a repository for the mongo driver.
+// The media storage, the mailer and the login providers are chosen by the
+// configuration.
 func NewMongoDBRepo(a *config.AppConfig, db *mongo.Database) *Repository {
+	store := storage.NewGridFSStorage(db)
+	if a.Config.Media.Storage == "local" {
+		var err error
+		store, err = storage.NewLocalStorage(a.Config.Media.Dir)
+		if err != nil {
+			a.Logger.Fatal(err)
+		}
+	}
+
+	mail := mailer.NewSMTPMailer(a.Config.Mail.SMTP.Host, a.Config.Mail.SMTP.Port,
+		a.Config.Mail.SMTP.Username, a.Config.Mail.SMTP.Password, a.Config.Mail.From)
+	if a.Config.Mail.Transport == "file" {
+		var err error
+		mail, err = mailer.NewFileMailer(a.Config.Mail.Dir, a.Logger)
+		if err != nil {
+			a.Logger.Fatal(err)
+		}
+	}
+
 	return &Repository{
-		App: a,
-		DB:  dbrepo.NewMongoDBRepo(a, db),
+		App:     a,
+		DB:      dbrepo.NewMongoDBRepo(a, db),
+		Storage: store,
+		Mailer:  mail,
+		OIDC:    newOIDCProviders(a),
 	}
 }
 
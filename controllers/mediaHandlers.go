@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/storage"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UploadMedia is the handler for uploading an image as multipart form with
+// the field "file". The content type is detected from the uploaded bytes,
+// EXIF data is stripped from JPEGs and thumbnails are created for all
+// configured widths below the width of the image.
+func (m *Repository) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	maxSize := m.App.Config.Media.MaxSize
+
+	// leave some room for the multipart boundaries and headers
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		errorJSON(w, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if int64(len(data)) > maxSize {
+		errorJSON(w, fmt.Errorf("file is larger than %d bytes", maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := utils.ImageTypes[contentType]; !ok {
+		errorJSON(w, fmt.Errorf("content type %s is not allowed", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if contentType == "image/jpeg" {
+		data, err = utils.StripEXIF(data)
+		if err != nil {
+			errorJSON(w, err)
+			return
+		}
+	}
+
+	img, err := utils.DecodeImage(data)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	key, err := utils.RandomToken(16)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	media := models.Media{
+		Owner:       userID,
+		Name:        header.Filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Key:         key,
+		Thumbnails:  []models.Thumbnail{},
+		CreatedAt:   time.Now(),
+	}
+
+	files := map[string][]byte{key: data}
+	for _, width := range m.App.Config.Media.ThumbnailWidths {
+		if width >= media.Width {
+			continue
+		}
+
+		thumb := utils.Thumbnail(img, width)
+		thumbData, thumbType, err := utils.EncodeImage(thumb, contentType)
+		if err != nil {
+			errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		thumbnail := models.Thumbnail{
+			Width:       thumb.Bounds().Dx(),
+			Height:      thumb.Bounds().Dy(),
+			ContentType: thumbType,
+			Key:         fmt.Sprintf("%s-%d", key, width),
+		}
+		media.Thumbnails = append(media.Thumbnails, thumbnail)
+		files[thumbnail.Key] = thumbData
+	}
+
+	for fileKey, fileData := range files {
+		err = m.Storage.Save(fileKey, bytes.NewReader(fileData))
+		if err != nil {
+			m.deleteMediaFiles(&media)
+			errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	id, err := m.DB.InsertMedia(media)
+	if err != nil {
+		m.deleteMediaFiles(&media)
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	media.ID = *id
+
+	err = writeJSON(w, http.StatusCreated, media)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetMediaById is the handler for getting the metadata of a media by its ID.
+func (m *Repository) GetMediaById(w http.ResponseWriter, r *http.Request) {
+	media, ok := m.getMedia(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	err := writeJSON(w, http.StatusOK, media)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetMediaFile is the handler for downloading a media. The optional query
+// parameter "width" selects one of its thumbnails.
+func (m *Repository) GetMediaFile(w http.ResponseWriter, r *http.Request) {
+	media, ok := m.getMedia(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	key := media.Key
+	contentType := media.ContentType
+	if widthString := r.URL.Query().Get("width"); widthString != "" {
+		width, err := strconv.Atoi(widthString)
+		if err != nil {
+			errorJSON(w, err)
+			return
+		}
+
+		key = ""
+		for _, thumbnail := range media.Thumbnails {
+			if thumbnail.Width == width {
+				key = thumbnail.Key
+				contentType = thumbnail.ContentType
+			}
+		}
+		if key == "" {
+			errorJSON(w, errors.New("thumbnail not found"), http.StatusNotFound)
+			return
+		}
+	}
+
+	file, err := m.Storage.Open(key)
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// DeleteMedia is the handler for deleting a media which is not used by any
+// post anymore.
+func (m *Repository) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	media, ok := m.getMedia(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	err := m.DB.DeleteMedia(media.ID)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		if err.Error() == dbrepo.ErrorMediaInUse {
+			errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.deleteMediaFiles(media)
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// getMedia fetches the metadata of a media and writes the error response if
+// there is none.
+func (m *Repository) getMedia(w http.ResponseWriter, id string) (*models.Media, bool) {
+	media, err := m.DB.GetMediaById(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+			return nil, false
+		}
+		errorJSON(w, err)
+		return nil, false
+	}
+
+	return media, true
+}
+
+// deleteMediaFiles removes the original and all thumbnails of a media from
+// the storage. Errors are only logged since the metadata is already gone.
+func (m *Repository) deleteMediaFiles(media *models.Media) {
+	keys := []string{media.Key}
+	for _, thumbnail := range media.Thumbnails {
+		keys = append(keys, thumbnail.Key)
+	}
+
+	for _, key := range keys {
+		err := m.Storage.Delete(key)
+		if err != nil && err != storage.ErrFileNotFound {
+			m.App.Logger.Println(err)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/oidc"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// oidcStateTTL is how long a login at a provider can take.
+const oidcStateTTL = 10 * time.Minute
+
+// oidcCookiePath limits the cookie binding a provider login to the browser
+// which started it to the provider routes.
+const oidcCookiePath = "/v1/users/oidc"
+
+var errOIDCNoEmail = errors.New("the provider did not share an email")
+var errOIDCEmailTaken = errors.New("a user with the unverified email of the provider already exists")
+var errOIDCUserUnverified = errors.New("a user with the email of the provider already exists, but never verified it")
+
+// newOIDCProviders creates the configured login providers. Their redirect URL
+// is the callback route below the site URL.
+func newOIDCProviders(a *config.AppConfig) map[string]*oidc.Provider {
+	providers := map[string]*oidc.Provider{}
+
+	for name, p := range a.Config.OIDC {
+		redirectURL := a.Config.Site.URL + oidcCookiePath + "/" + name + "/callback"
+		providers[name] = oidc.NewProvider(name, p.Issuer, p.ClientID, p.ClientSecret, redirectURL, p.Scopes)
+	}
+
+	return providers
+}
+
+// OIDCLogin is the handler for starting a login at an external provider. It
+// redirects to the provider using the authorization code flow with PKCE.
+func (m *Repository) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := m.OIDC[chi.URLParam(r, "provider")]
+	if !ok {
+		errorJSON(w, errors.New("unknown login provider"), http.StatusNotFound)
+		return
+	}
+
+	state, err := utils.RandomToken(32)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	nonce, err := utils.RandomToken(32)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	verifier, err := utils.RandomToken(32)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		errorJSON(w, err, http.StatusBadGateway)
+		return
+	}
+
+	expiresAt := time.Now().Add(oidcStateTTL)
+
+	err = m.DB.InsertOIDCState(models.OIDCState{
+		State:     state,
+		Provider:  provider.Name,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.setCookie(w, m.oidcCookieName(), state, oidcCookiePath, expiresAt)
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback is the handler the provider redirects to after the login. It
+// verifies the ID token, links the identity to a user, who is created on the
+// first login, and starts a session like Login.
+func (m *Repository) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := m.OIDC[chi.URLParam(r, "provider")]
+	if !ok {
+		errorJSON(w, errors.New("unknown login provider"), http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		errorJSON(w, errors.New("login at the provider failed: "+query.Get("error")+" "+query.Get("error_description")))
+		return
+	}
+
+	// the state has to come back to the browser which started the login
+	cookie, err := r.Cookie(m.oidcCookieName())
+	if err != nil || cookie.Value == "" || cookie.Value != query.Get("state") {
+		errorJSON(w, errors.New(dbrepo.ErrorInvalidToken))
+		return
+	}
+	m.setCookie(w, m.oidcCookieName(), "", oidcCookiePath, time.Now().Add(-time.Hour))
+
+	state, err := m.DB.UseOIDCState(provider.Name, cookie.Value)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorInvalidToken {
+			errorJSON(w, err)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	idToken, err := provider.Exchange(r.Context(), query.Get("code"), state.Verifier)
+	if err != nil {
+		errorJSON(w, err, http.StatusBadGateway)
+		return
+	}
+
+	claims, err := provider.Verify(r.Context(), idToken, state.Nonce)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := m.oidcUser(provider.Name, claims)
+	if err != nil {
+		if err == errOIDCNoEmail {
+			errorJSON(w, err)
+			return
+		} else if err == errOIDCEmailTaken || err == errOIDCUserUnverified {
+			errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.canLogIn(user)
+	if err != nil {
+		errorJSON(w, err, http.StatusForbidden)
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		m.startTwoFactorChallenge(w, user)
+		return
+	}
+
+	tokens, err := m.startSession(r, user.ID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.writeSession(w, user.ID, tokens, false)
+}
+
+// oidcUser finds the user linked to the identity. Without one, the identity
+// is linked to the user with the same email if both the provider and the
+// user verified it, or else a new user is created.
+// Returns the user and an error if any occurred.
+func (m *Repository) oidcUser(provider string, claims *oidc.Claims) (*models.User, error) {
+	user, err := m.DB.GetUserByIdentity(provider, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, errOIDCNoEmail
+	}
+
+	user, err = m.DB.GetUserByEmail(claims.Email)
+	if err == nil {
+		// linking an unverified email would hand the account to whoever
+		// claims it at the provider
+		if !claims.EmailVerified {
+			return nil, errOIDCEmailTaken
+		}
+		// whoever registered the email first without verifying it could
+		// keep logging in with the password of the linked account
+		if m.App.Config.EmailVerification == models.EmailVerificationOff || !user.EmailVerified() {
+			return nil, errOIDCUserUnverified
+		}
+
+		err = m.DB.LinkIdentity(user.ID, provider, claims.Subject)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	newUser := models.User{
+		Name:       oidcUserName(claims),
+		Email:      claims.Email,
+		Roles:      []string{models.RoleUser},
+		Identities: []models.Identity{{Provider: provider, Subject: claims.Subject}},
+		CreatedAt:  time.Now(),
+	}
+
+	if !claims.EmailVerified {
+		newUser.EmailVerificationID, err = m.newVerificationID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := m.DB.InsertUser(newUser)
+	if err != nil {
+		return nil, err
+	}
+	newUser.ID = *id
+
+	// the user can ask for a new token if sending this one fails
+	if newUser.EmailVerificationID != "" {
+		err = m.mailVerification(newUser.ID, newUser.Email, newUser.EmailVerificationID)
+		if err != nil {
+			m.App.Logger.Println("could not send verification mail:", err)
+		}
+	}
+
+	return &newUser, nil
+}
+
+// oidcCookieName is the name of the cookie holding the state of a provider
+// login.
+func (m *Repository) oidcCookieName() string {
+	return m.App.Config.Cookie.Name + "-oidc"
+}
+
+// oidcUserName picks the name of a new user from the claims of the provider
+// and fits it into the allowed length of user names.
+func oidcUserName(claims *oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name = claims.Name
+	}
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	name = strings.TrimSpace(name)
+	for utf8.RuneCountInString(name) > 20 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if utf8.RuneCountInString(name) < 3 {
+		name = "user"
+	}
+
+	return name
+}
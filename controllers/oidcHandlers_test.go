@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/mailer"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/oidc"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// oidcTestDB keeps the users the login with a provider looks up, links and
+// inserts. The other methods are the ones of the testing repository.
+type oidcTestDB struct {
+	database.DatabaseRepo
+
+	users    []models.User
+	linked   []string
+	inserted []models.User
+}
+
+func (db *oidcTestDB) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	for i, u := range db.users {
+		for _, identity := range u.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return &db.users[i], nil
+			}
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (db *oidcTestDB) GetUserByEmail(email string) (*models.User, error) {
+	for i, u := range db.users {
+		if u.Email == email {
+			return &db.users[i], nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (db *oidcTestDB) LinkIdentity(userID, provider, subject string) error {
+	db.linked = append(db.linked, userID)
+	return nil
+}
+
+func (db *oidcTestDB) InsertUser(u models.User) (*string, error) {
+	db.inserted = append(db.inserted, u)
+	id := "000000000000000000000999"
+	return &id, nil
+}
+
+// oidcTestMailer keeps the sent mails.
+type oidcTestMailer struct {
+	sent []mailer.Mail
+}
+
+func (m *oidcTestMailer) Send(mail mailer.Mail) error {
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+func TestOIDCUser(t *testing.T) {
+	verified := models.User{
+		ID:    "000000000000000000000101",
+		Name:  "verified",
+		Email: "verified@example.com",
+	}
+	unverified := models.User{
+		ID:                  "000000000000000000000102",
+		Name:                "unverified",
+		Email:               "unverified@example.com",
+		EmailVerificationID: "pending",
+	}
+	linked := models.User{
+		ID:         "000000000000000000000103",
+		Name:       "linked",
+		Email:      "linked@example.com",
+		Identities: []models.Identity{{Provider: "test", Subject: "subject-linked"}},
+	}
+
+	tests := []struct {
+		name              string
+		emailVerification string
+		claims            oidc.Claims
+		err               error
+		userID            string
+		linked            bool
+		inserted          bool
+		mailed            bool
+	}{
+		{
+			name:   "linked identity",
+			claims: oidc.Claims{Subject: "subject-linked", Email: "other@example.com"},
+			userID: linked.ID,
+		},
+		{
+			name:   "no email",
+			claims: oidc.Claims{Subject: "subject-1"},
+			err:    errOIDCNoEmail,
+		},
+		{
+			name:   "verified email of a verified user",
+			claims: oidc.Claims{Subject: "subject-1", Email: verified.Email, EmailVerified: true},
+			userID: verified.ID,
+			linked: true,
+		},
+		{
+			name:   "unverified email of a verified user",
+			claims: oidc.Claims{Subject: "subject-1", Email: verified.Email},
+			err:    errOIDCEmailTaken,
+		},
+		{
+			name:   "verified email of an unverified user",
+			claims: oidc.Claims{Subject: "subject-1", Email: unverified.Email, EmailVerified: true},
+			err:    errOIDCUserUnverified,
+		},
+		{
+			name:              "verified email of a user without verification",
+			emailVerification: models.EmailVerificationOff,
+			claims:            oidc.Claims{Subject: "subject-1", Email: verified.Email, EmailVerified: true},
+			err:               errOIDCUserUnverified,
+		},
+		{
+			name:     "verified email of a new user",
+			claims:   oidc.Claims{Subject: "subject-1", Email: "new@example.com", EmailVerified: true},
+			userID:   "000000000000000000000999",
+			inserted: true,
+		},
+		{
+			name:     "unverified email of a new user",
+			claims:   oidc.Claims{Subject: "subject-1", Email: "new@example.com"},
+			userID:   "000000000000000000000999",
+			inserted: true,
+			mailed:   true,
+		},
+		{
+			name:              "unverified email of a new user without verification",
+			emailVerification: models.EmailVerificationOff,
+			claims:            oidc.Claims{Subject: "subject-1", Email: "new@example.com"},
+			userID:            "000000000000000000000999",
+			inserted:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &config.AppConfig{Logger: log.New(io.Discard, "", 0)}
+			app.Config.JWT = []byte("secret")
+			app.Config.EmailVerification = models.EmailVerificationLimit
+			if tt.emailVerification != "" {
+				app.Config.EmailVerification = tt.emailVerification
+			}
+
+			db := &oidcTestDB{
+				DatabaseRepo: dbrepo.NewTestingRepo(app),
+				users:        []models.User{verified, unverified, linked},
+			}
+			mail := &oidcTestMailer{}
+			m := &Repository{App: app, DB: db, Mailer: mail}
+
+			claims := tt.claims
+			user, err := m.oidcUser("test", &claims)
+			if err != tt.err {
+				t.Fatalf("oidcUser error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if len(db.linked) != 0 || len(db.inserted) != 0 {
+					t.Errorf("oidcUser linked %v and inserted %v after refusing", db.linked, db.inserted)
+				}
+				return
+			}
+
+			if user.ID != tt.userID {
+				t.Errorf("oidcUser returned user %s, want %s", user.ID, tt.userID)
+			}
+			if (len(db.linked) != 0) != tt.linked {
+				t.Errorf("oidcUser linked %v, want linking %t", db.linked, tt.linked)
+			}
+			if (len(db.inserted) != 0) != tt.inserted {
+				t.Errorf("oidcUser inserted %v, want inserting %t", db.inserted, tt.inserted)
+			}
+			if (len(mail.sent) != 0) != tt.mailed {
+				t.Errorf("oidcUser sent %d mails, want mailing %t", len(mail.sent), tt.mailed)
+			}
+
+			if tt.inserted {
+				newUser := db.inserted[0]
+				if len(newUser.Identities) != 1 || newUser.Identities[0] != (models.Identity{Provider: "test", Subject: "subject-1"}) {
+					t.Errorf("oidcUser inserted identities %v", newUser.Identities)
+				}
+				if newUser.EmailVerified() == tt.mailed {
+					t.Errorf("oidcUser inserted user with verified email %t, want %t", newUser.EmailVerified(), !tt.mailed)
+				}
+			}
+		})
+	}
+}
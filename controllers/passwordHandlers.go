@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/mailer"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetTTL is how long a password reset token stays valid.
+const passwordResetTTL = time.Hour
+
+// ForgotPassword is the handler for mailing a password reset token to a user.
+// It always answers the same way and right away, since the user is looked up
+// and mailed in the background, so it cannot be used to find out which emails
+// are registered.
+func (m *Repository) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request models.EmailRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	go m.forgotPassword(request.Email)
+
+	type jsonResp struct {
+		OK bool `json:"ok"`
+	}
+
+	response := jsonResp{
+		OK: true,
+	}
+
+	err = writeJSON(w, http.StatusAccepted, response)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// forgotPassword sends a password reset token if the email belongs to a user.
+// Errors are only logged, since nobody waits for them.
+func (m *Repository) forgotPassword(email string) {
+	user, err := m.DB.GetUserByEmail(email)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			m.App.Logger.Println("could not look up user for password reset mail:", err)
+		}
+		return
+	}
+
+	err = m.sendPasswordReset(user.ID, user.Email)
+	if err != nil {
+		m.App.Logger.Println("could not send password reset mail:", err)
+	}
+}
+
+// ResetPassword is the handler for setting a new password with a password
+// reset token. All existing sessions of the user are logged out.
+func (m *Repository) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request models.PasswordResetRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	passwordValid := utils.PasswordIsValid(request.Password)
+	if !passwordValid {
+		err = errors.New("password is not valid")
+		errorJSON(w, err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), 12)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.DB.ResetPassword(utils.HashToken(request.Token), string(hashedPassword))
+	if err != nil {
+		if err.Error() == dbrepo.ErrorInvalidToken {
+			errorJSON(w, err)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// sendPasswordReset creates a new password reset token for the user, which
+// replaces all previous tokens, and mails it to the given email. Only the
+// hash of the token is stored.
+// Returns an error if any occurred.
+func (m *Repository) sendPasswordReset(userID, email string) error {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB.SetPasswordReset(userID, utils.HashToken(token), time.Now().Add(passwordResetTTL))
+	if err != nil {
+		return err
+	}
+
+	return m.Mailer.Send(mailer.Mail{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Somebody asked to reset the password of your account. To choose a new password send this token with it to %s/v1/users/password/reset:\n\n%s\n\nThe token expires in %s. If you did not ask for it, you can ignore this mail.",
+			m.App.Config.Site.URL, token, passwordResetTTL),
+	})
+}
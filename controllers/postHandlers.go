@@ -4,14 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-playground/validator/v10"
 	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
 	"github.com/schattenbrot/mini-blog-api/models"
 	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // InsertPost is the handler for adding posts.
@@ -26,6 +27,8 @@ func (m *Repository) InsertPost(w http.ResponseWriter, r *http.Request) {
 
 	post.CreatedAt = time.Now()
 	post.UpdatedAt = time.Now()
+	post.Tags = utils.NormalizeTags(post.Tags)
+	post.Category = utils.NormalizeTag(post.Category)
 
 	err = m.App.Validator.Struct(post)
 	if err != nil {
@@ -38,13 +41,34 @@ func (m *Repository) InsertPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := utils.GetIssuerFromCookie(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
 	if err != nil {
 		errorJSON(w, err)
 		return
 	}
 	post.Creator = userID
 
+	// users who may not publish start with a draft
+	canPublish := m.can(r, models.PermPostPublish)
+	if post.Status == "" {
+		post.Status = models.PostStatusPublished
+		if !canPublish {
+			post.Status = models.PostStatusDraft
+		}
+	}
+	if publishes(post.Status) && !canPublish {
+		errorJSON(w, errors.New("not allowed to publish posts"), http.StatusForbidden)
+		return
+	}
+	err = preparePostStatus(&post)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+	if !m.mediaExist(w, r, post.Media, userID) {
+		return
+	}
+
 	id, err := m.DB.InsertPost(post)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
@@ -84,15 +108,97 @@ func (m *Repository) GetPostById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !m.canSeePost(r, post) {
+		errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+		return
+	}
+
+	err = renderPosts(post)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	m.addSeriesNavigation(r, post)
+
+	err = writeJSON(w, http.StatusOK, post)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetPostBySlug is the handler for getting a post by its slug.
+// Old slugs of a post answer with a permanent redirect to its current slug.
+func (m *Repository) GetPostBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	post, err := m.DB.GetPostBySlug(slug)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !m.canSeePost(r, post) {
+		errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+		return
+	}
+
+	if post.Slug != slug {
+		http.Redirect(w, r, "/v1/posts/by-slug/"+url.PathEscape(post.Slug), http.StatusMovedPermanently)
+		return
+	}
+
+	err = renderPosts(post)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	m.addSeriesNavigation(r, post)
+
 	err = writeJSON(w, http.StatusOK, post)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
+// GetDrafts is the handler for retrieving the drafts and scheduled posts of
+// the logged in user.
+func (m *Repository) GetDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	posts, err := m.DB.GetDraftsByCreator(userID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = renderPosts(posts...)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, posts)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
 // GetAllPosts is the handler for retrieving all posts.
 func (m *Repository) GetAllPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := m.DB.GetPosts()
+	posts, err := m.DB.GetPosts(postFilterFromQuery(r))
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = renderPosts(posts...)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
 		return
@@ -106,21 +212,19 @@ func (m *Repository) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 
 // GetPaginatedPost is the handler for retrieving a paginated slice of posts.
 func (m *Repository) GetPaginatedPosts(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-
-	limit, err := strconv.Atoi(query.Get("limit"))
+	page, limit, err := paginationFromQuery(r)
 	if err != nil {
 		errorJSON(w, err)
 		return
 	}
 
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	posts, err := m.DB.GetPostsByPage(page, limit, postFilterFromQuery(r))
 	if err != nil {
-		errorJSON(w, err)
+		errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	posts, err := m.DB.GetPostsByPage(page, limit)
+	err = renderPosts(posts...)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
 		return
@@ -133,8 +237,62 @@ func (m *Repository) GetPaginatedPosts(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// SearchPosts is the handler for the full text search over published posts.
+// The results are paginated like GetPaginatedPosts.
+func (m *Repository) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	terms := utils.SearchTerms(query)
+	if len(terms) == 0 {
+		errorJSON(w, errors.New("the search query q is required"))
+		return
+	}
+
+	page, limit, err := paginationFromQuery(r)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	results, err := m.DB.SearchPosts(query, page, limit)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, result := range results {
+		result.Snippet = utils.Highlight(result.Text, terms)
+
+		err = renderPosts(&result.Post)
+		if err != nil {
+			errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = writeJSON(w, http.StatusOK, results)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetTags is the handler for retrieving all tags of published posts together
+// with their usage count.
+func (m *Repository) GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := m.DB.GetTagCounts()
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, tags)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
 // UpdatePostById is the handler for updating a post by its ID.
-// The body of the update needs either the text or the title of the post.
+// The body of the update needs at least one of the text, title, status, tags
+// or category of the post.
 func (m *Repository) UpdatePostById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -145,15 +303,45 @@ func (m *Repository) UpdatePostById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	post.ID = id
+	post.Tags = utils.NormalizeTags(post.Tags)
+	post.Category = utils.NormalizeTag(post.Category)
 
-	v := validator.New()
-	err = v.Struct(post)
+	err = m.App.Validator.Struct(post)
 	if err != nil {
 		errorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
-	err = m.DB.UpdatePost(post)
+	editor, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	if post.Status != "" {
+		if publishes(post.Status) && !m.can(r, models.PermPostPublish) {
+			errorJSON(w, errors.New("not allowed to publish posts"), http.StatusForbidden)
+			return
+		}
+		err = preparePostStatus(&post)
+		if err != nil {
+			errorJSON(w, err)
+			return
+		}
+	}
+	if len(post.Media) > 0 {
+		// editors keep the media of the creator
+		owners := []string{editor}
+		creator, err := m.DB.GetPostCreator(id)
+		if err == nil && creator != editor {
+			owners = append(owners, creator)
+		}
+		if !m.mediaExist(w, r, post.Media, owners...) {
+			return
+		}
+	}
+
+	err = m.DB.UpdatePost(post, editor)
 	if err != nil {
 		if err.Error() == dbrepo.ErrorDocumentNotFound {
 			errorJSON(w, err, http.StatusNotFound)
@@ -161,6 +349,9 @@ func (m *Repository) UpdatePostById(w http.ResponseWriter, r *http.Request) {
 		} else if err.Error() == dbrepo.ErrorAlreadyUpToDate {
 			errorJSON(w, err, http.StatusOK)
 			return
+		} else if err.Error() == dbrepo.ErrorConcurrentUpdate {
+			errorJSON(w, err, http.StatusConflict)
+			return
 		}
 
 		errorJSON(w, err, http.StatusInternalServerError)
@@ -191,3 +382,128 @@ func (m *Repository) DeletePost(w http.ResponseWriter, r *http.Request) {
 		errorJSON(w, err, http.StatusInternalServerError)
 	}
 }
+
+// renderPosts renders the markdown text of the posts to HTML together with
+// their table of contents.
+func renderPosts(posts ...*models.Post) error {
+	for _, post := range posts {
+		html, toc, err := utils.RenderMarkdown(post.Text)
+		if err != nil {
+			return err
+		}
+		post.HTML = html
+		post.TOC = toc
+	}
+
+	return nil
+}
+
+// mediaExist checks if all media referenced by a post or avatar exist and
+// belong to one of the owners, and writes the error response if not. Users
+// who may delete any media may reference any media, so nobody else can keep
+// an upload from being deleted.
+func (m *Repository) mediaExist(w http.ResponseWriter, r *http.Request, ids []string, owners ...string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+
+	if m.can(r, models.PermMediaDeleteAny) {
+		owners = nil
+	}
+
+	exist, err := m.DB.MediaExist(ids, owners)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return false
+	}
+	if !exist {
+		errorJSON(w, errors.New("referenced media does not exist or belongs to another user"))
+		return false
+	}
+
+	return true
+}
+
+// preparePostStatus checks the status of a post against its publish time.
+// Published posts without a publish time are published right away, scheduled
+// posts need a publish time in the future.
+func preparePostStatus(post *models.Post) error {
+	switch post.Status {
+	case models.PostStatusPublished:
+		if post.PublishAt.IsZero() {
+			post.PublishAt = time.Now()
+		}
+	case models.PostStatusScheduled:
+		if !post.PublishAt.After(time.Now()) {
+			return errors.New("scheduled posts need a publish_at in the future")
+		}
+	default:
+		post.PublishAt = time.Time{}
+	}
+
+	return nil
+}
+
+// publishes checks if a post with the given status is or will become public.
+func publishes(status string) bool {
+	return status == models.PostStatusPublished || status == models.PostStatusScheduled
+}
+
+// canSeePost checks if the requesting user is allowed to see the given post.
+// Public posts can be seen by everyone, everything else only by its creator
+// or users allowed to read any post.
+func (m *Repository) canSeePost(r *http.Request, post *models.Post) bool {
+	if post.IsPublic(time.Now()) {
+		return true
+	}
+
+	auth, ok := utils.GetAuthentication(r)
+	if !ok {
+		return false
+	}
+
+	return auth.UserID == post.Creator || m.can(r, models.PermPostReadAny)
+}
+
+// can checks if the roles of the requesting user grant the permission and
+// the API key of the request, if any, allows it.
+func (m *Repository) can(r *http.Request, permission string) bool {
+	auth, ok := utils.GetAuthentication(r)
+	if !ok {
+		return false
+	}
+
+	return utils.RequestHasPermission(r, m.App.Config.Roles, m.DB.GetUserRoles, auth.UserID, permission)
+}
+
+// postFilterFromQuery reads the "tag" and "category" filters of the post
+// listings from the query parameters.
+func postFilterFromQuery(r *http.Request) models.PostFilter {
+	query := r.URL.Query()
+
+	return models.PostFilter{
+		Tag:      utils.NormalizeTag(query.Get("tag")),
+		Category: utils.NormalizeTag(query.Get("category")),
+	}
+}
+
+// paginationFromQuery reads the "page" and "limit" query parameters.
+func paginationFromQuery(r *http.Request) (int, int, error) {
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if page < 1 || limit < 1 {
+		return 0, 0, errors.New("page and limit need to be positive")
+	}
+
+	return page, limit, nil
+}
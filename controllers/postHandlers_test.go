@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+)
+
+// mediaTestDB keeps the owners media references are checked against. The
+// other methods are the ones of the testing repository.
+type mediaTestDB struct {
+	database.DatabaseRepo
+
+	roles  []string
+	owners []string
+	exist  bool
+}
+
+func (db *mediaTestDB) GetUserRoles(id string) ([]string, error) {
+	return db.roles, nil
+}
+
+func (db *mediaTestDB) MediaExist(ids []string, owners []string) (bool, error) {
+	db.owners = owners
+	return db.exist, nil
+}
+
+func TestMediaExist(t *testing.T) {
+	tests := []struct {
+		name   string
+		roles  []string
+		scopes []string
+		exist  bool
+		owners []string
+		status int
+	}{
+		{"own media", []string{models.RoleUser}, nil, true, []string{"owner"}, 0},
+		{"foreign media", []string{models.RoleUser}, nil, false, []string{"owner"}, http.StatusBadRequest},
+		{"any media with media:delete:any", []string{models.RoleAdmin}, nil, true, nil, 0},
+		{"own media with a key which can't delete any media", []string{models.RoleAdmin}, []string{models.ScopePostsRead}, true, []string{"owner"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &config.AppConfig{Logger: log.New(io.Discard, "", 0)}
+			app.Config.Roles = map[string][]string{
+				models.RoleUser:  {models.PermPostCreate, models.PermMediaUpload},
+				models.RoleAdmin: {models.PermAll},
+			}
+
+			db := &mediaTestDB{DatabaseRepo: dbrepo.NewTestingRepo(app), roles: tt.roles, exist: tt.exist}
+			m := &Repository{App: app, DB: db}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/posts", nil)
+			r = utils.WithAuthentication(r, utils.Authentication{
+				UserID: "caller",
+				APIKey: tt.scopes != nil,
+				Scopes: tt.scopes,
+			})
+			w := httptest.NewRecorder()
+
+			ok := m.mediaExist(w, r, []string{"000000000000000000000001"}, "owner")
+			if ok != (tt.status == 0) || (!ok && w.Code != tt.status) {
+				t.Errorf("mediaExist = %t with status %d, want status %d", ok, w.Code, tt.status)
+			}
+			if !reflect.DeepEqual(db.owners, tt.owners) {
+				t.Errorf("mediaExist checked owners %v, want %v", db.owners, tt.owners)
+			}
+		})
+	}
+}
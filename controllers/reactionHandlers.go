@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+)
+
+// AddReaction is the handler for reacting to a post. Reacting twice with the
+// same kind has no further effect.
+func (m *Repository) AddReaction(w http.ResponseWriter, r *http.Request) {
+	m.changeReaction(w, r, m.DB.AddReaction)
+}
+
+// RemoveReaction is the handler for taking back a reaction to a post.
+func (m *Repository) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	m.changeReaction(w, r, m.DB.RemoveReaction)
+}
+
+// changeReaction validates a reaction request and applies the change.
+func (m *Repository) changeReaction(w http.ResponseWriter, r *http.Request, change func(postID, userID, kind string) error) {
+	postID := chi.URLParam(r, "id")
+	kind := chi.URLParam(r, "kind")
+
+	if !models.IsReactionKind(kind) {
+		errorJSON(w, fmt.Errorf("reaction %s is not one of %v", kind, models.ReactionKinds))
+		return
+	}
+
+	_, ok := m.getVisiblePost(w, r, postID)
+	if !ok {
+		return
+	}
+
+	auth, ok := utils.GetAuthentication(r)
+	if !ok {
+		errorJSON(w, errors.New("not authenticated"), http.StatusUnauthorized)
+		return
+	}
+
+	err := change(postID, auth.UserID, kind)
+	if err != nil && err.Error() != dbrepo.ErrorAlreadyUpToDate {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetRevisions is the handler for retrieving all revisions of a post.
+func (m *Repository) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	revisions, err := m.DB.GetRevisions(id)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, revisions)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetRevision is the handler for retrieving a single revision of a post.
+func (m *Repository) GetRevision(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	number, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	revision, err := m.getRevision(w, id, number)
+	if err != nil {
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, revision)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetRevisionDiff is the handler for creating a diff between two revisions of
+// a post. The revisions are taken from the query parameters "from" and "to".
+func (m *Repository) GetRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	query := r.URL.Query()
+
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	to, err := strconv.Atoi(query.Get("to"))
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	fromRevision, err := m.getRevision(w, id, from)
+	if err != nil {
+		return
+	}
+
+	toRevision, err := m.getRevision(w, id, to)
+	if err != nil {
+		return
+	}
+
+	diff := models.RevisionDiff{
+		From:  from,
+		To:    to,
+		Title: utils.DiffLines(fromRevision.Title, toRevision.Title),
+		Text:  utils.DiffLines(fromRevision.Text, toRevision.Text),
+	}
+
+	err = writeJSON(w, http.StatusOK, diff)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// RestoreRevision is the handler for restoring the content of a post from one
+// of its revisions. The restore itself is stored as a new revision.
+func (m *Repository) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	number, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	revision, err := m.getRevision(w, id, number)
+	if err != nil {
+		return
+	}
+
+	editor, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	post := models.Post{
+		ID:    id,
+		Title: revision.Title,
+		Text:  revision.Text,
+	}
+
+	err = m.DB.UpdatePost(post, editor)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		} else if err.Error() == dbrepo.ErrorAlreadyUpToDate {
+			errorJSON(w, err, http.StatusOK)
+			return
+		} else if err.Error() == dbrepo.ErrorConcurrentUpdate {
+			errorJSON(w, err, http.StatusConflict)
+			return
+		}
+
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// getRevision fetches a single revision and writes the error response if it
+// could not be found.
+func (m *Repository) getRevision(w http.ResponseWriter, postID string, number int) (*models.Revision, error) {
+	revision, err := m.DB.GetRevision(postID, number)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, errors.New(dbrepo.ErrorDocumentNotFound), http.StatusNotFound)
+			return nil, err
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return nil, err
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InsertSeries is the handler for adding a series.
+func (m *Repository) InsertSeries(w http.ResponseWriter, r *http.Request) {
+	var series models.Series
+
+	err := json.NewDecoder(r.Body).Decode(&series)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(series)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+	if series.Title == "" {
+		errorJSON(w, errors.New("title is required"))
+		return
+	}
+
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	if !m.canAddToSeries(w, r, userID, series.Posts) {
+		return
+	}
+
+	series.Creator = userID
+	series.CreatedAt = time.Now()
+	series.UpdatedAt = time.Now()
+
+	id, err := m.DB.InsertSeries(series)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	type jsonResp struct {
+		OK bool    `json:"ok"`
+		ID *string `json:"id"`
+	}
+
+	response := jsonResp{
+		OK: true,
+		ID: id,
+	}
+
+	err = writeJSON(w, http.StatusCreated, response)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetAllSeries is the handler for retrieving all series.
+func (m *Repository) GetAllSeries(w http.ResponseWriter, r *http.Request) {
+	series, err := m.DB.GetAllSeries()
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, series)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetSeriesById is the handler for getting a series by its ID.
+func (m *Repository) GetSeriesById(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	series, err := m.DB.GetSeriesById(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, series)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// UpdateSeriesById is the handler for updating a series by its ID.
+// The body of the update needs at least one of the title, description or
+// posts of the series. The posts replace the current order of the series.
+func (m *Repository) UpdateSeriesById(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var series models.Series
+	err := json.NewDecoder(r.Body).Decode(&series)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+	series.ID = id
+
+	err = m.App.Validator.Struct(series)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	creator, err := m.DB.GetSeriesCreator(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !m.canAddToSeries(w, r, creator, series.Posts) {
+		return
+	}
+
+	err = m.DB.UpdateSeries(series)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		} else if err.Error() == dbrepo.ErrorSeriesEmpty {
+			errorJSON(w, err)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// DeleteSeries is the handler for deleting a series by its ID.
+// The posts of the series are kept.
+func (m *Repository) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	err := m.DB.DeleteSeries(id)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// canAddToSeries checks if all posts exist and belong to the creator of the
+// series. Requesting users allowed to edit any series may collect posts of
+// other users. Writes the error response if not.
+func (m *Repository) canAddToSeries(w http.ResponseWriter, r *http.Request, creator string, postIDs []string) bool {
+	if len(postIDs) == 0 {
+		return true
+	}
+
+	editAny := m.can(r, models.PermSeriesEditAny)
+	for _, postID := range postIDs {
+		postCreator, err := m.DB.GetPostCreator(postID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				errorJSON(w, fmt.Errorf("post %s does not exist", postID))
+				return false
+			}
+			errorJSON(w, err, http.StatusInternalServerError)
+			return false
+		}
+
+		if postCreator != creator && !editAny {
+			errorJSON(w, fmt.Errorf("post %s belongs to another user", postID), http.StatusForbidden)
+			return false
+		}
+	}
+
+	return true
+}
+
+// addSeriesNavigation adds the position of a post inside its series together
+// with links to the previous and next post. Neighbours which the requesting
+// user is not allowed to see are skipped.
+func (m *Repository) addSeriesNavigation(r *http.Request, post *models.Post) {
+	series, err := m.DB.GetSeriesByPost(post.ID)
+	if err != nil || series == nil {
+		return
+	}
+
+	position := -1
+	for i, postID := range series.Posts {
+		if postID == post.ID {
+			position = i
+		}
+	}
+	if position < 0 {
+		return
+	}
+
+	navigation := &models.SeriesNavigation{
+		ID:       series.ID,
+		Title:    series.Title,
+		Position: position + 1,
+		Total:    len(series.Posts),
+	}
+
+	for i := position - 1; i >= 0 && navigation.Previous == nil; i-- {
+		navigation.Previous = m.seriesLink(r, series.Posts[i])
+	}
+	for i := position + 1; i < len(series.Posts) && navigation.Next == nil; i++ {
+		navigation.Next = m.seriesLink(r, series.Posts[i])
+	}
+
+	post.Series = navigation
+}
+
+// seriesLink creates the link to a post of a series if the requesting user is
+// allowed to see it.
+func (m *Repository) seriesLink(r *http.Request, postID string) *models.SeriesLink {
+	post, err := m.DB.GetPostById(postID)
+	if err != nil || post == nil || !m.canSeePost(r, post) {
+		return nil
+	}
+
+	return &models.SeriesLink{
+		ID:    post.ID,
+		Title: post.Title,
+		Slug:  post.Slug,
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// seriesTestDB knows the roles of the users and the creators of the posts.
+// The other methods are the ones of the testing repository.
+type seriesTestDB struct {
+	database.DatabaseRepo
+
+	roles    map[string][]string
+	creators map[string]string
+}
+
+func (db *seriesTestDB) GetUserRoles(id string) ([]string, error) {
+	return db.roles[id], nil
+}
+
+func (db *seriesTestDB) GetPostCreator(id string) (string, error) {
+	creator, ok := db.creators[id]
+	if !ok {
+		return "", mongo.ErrNoDocuments
+	}
+	return creator, nil
+}
+
+func TestCanAddToSeries(t *testing.T) {
+	const (
+		author = "author"
+		editor = "editor"
+	)
+	authorPost := "000000000000000000000001"
+	editorPost := "000000000000000000000002"
+
+	tests := []struct {
+		name      string
+		requester string
+		creator   string
+		posts     []string
+		status    int
+	}{
+		{"own posts", author, author, []string{authorPost}, 0},
+		{"foreign post", author, author, []string{authorPost, editorPost}, http.StatusForbidden},
+		{"editor in a series of another user", editor, author, []string{authorPost, editorPost}, 0},
+		{"foreign post in a series of an editor", author, editor, []string{editorPost, authorPost}, http.StatusForbidden},
+		{"unknown post", author, author, []string{"000000000000000000000003"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &config.AppConfig{Logger: log.New(io.Discard, "", 0)}
+			app.Config.Roles = map[string][]string{
+				models.RoleAuthor: {models.PermSeriesCreate},
+				models.RoleEditor: {models.PermSeriesCreate, models.PermSeriesEditAny},
+			}
+
+			db := &seriesTestDB{
+				DatabaseRepo: dbrepo.NewTestingRepo(app),
+				roles: map[string][]string{
+					author: {models.RoleAuthor},
+					editor: {models.RoleEditor},
+				},
+				creators: map[string]string{authorPost: author, editorPost: editor},
+			}
+			m := &Repository{App: app, DB: db}
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/series/1", nil)
+			r = utils.WithAuthentication(r, utils.Authentication{UserID: tt.requester})
+			w := httptest.NewRecorder()
+
+			ok := m.canAddToSeries(w, r, tt.creator, tt.posts)
+			if ok != (tt.status == 0) || (!ok && w.Code != tt.status) {
+				t.Errorf("canAddToSeries = %t with status %d, want status %d", ok, w.Code, tt.status)
+			}
+		})
+	}
+}
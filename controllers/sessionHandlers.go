@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/utils"
+)
+
+// GetSessions is the handler for listing the active sessions of the current
+// user.
+func (m *Repository) GetSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := utils.GetClaims(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := m.DB.GetActiveSessions(claims.Issuer)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == claims.Id
+	}
+
+	err = writeJSON(w, http.StatusOK, sessions)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// RevokeSession is the handler for ending one session of the current user.
+func (m *Repository) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+
+	claims, err := utils.GetClaims(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	err = m.DB.RevokeSession(claims.Issuer, sessionID)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorDocumentNotFound {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err)
+		return
+	}
+
+	if sessionID == claims.Id {
+		m.clearSessionCookies(w)
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// RevokeSessions is the handler for ending all sessions of the current user,
+// including the one of the request.
+func (m *Repository) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	err = m.DB.RevokeSessions(userID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.clearSessionCookies(w)
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
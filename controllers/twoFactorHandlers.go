@@ -0,0 +1,438 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+)
+
+// tokenPurposeTwoFactorLogin is the purpose of the signed challenge tokens of
+// the second login step.
+const tokenPurposeTwoFactorLogin = "login_2fa"
+
+// twoFactorChallengeTTL is how long the second login step can be finished.
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// twoFactorMaxAttempts is how many codes can be tried for one challenge.
+const twoFactorMaxAttempts = 5
+
+// recoveryCodeCount is the number of recovery codes created on enrolment.
+const recoveryCodeCount = 10
+
+// auditActionDisableTwoFactor and auditActionSetTwoFactorRoles are recorded
+// for the admin two-factor management.
+const (
+	auditActionDisableTwoFactor  = "user.disable_two_factor"
+	auditActionSetTwoFactorRoles = "settings.set_two_factor_roles"
+)
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// twoFactorChallenge is the response of a login which needs a second step.
+type twoFactorChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// EnrollTwoFactor is the handler for starting the two-factor enrolment of the
+// current user. It returns a new secret and its otpauth URI, which have to be
+// confirmed with a first code.
+func (m *Repository) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := m.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.DB.SetTwoFactorPending(user.ID, secret)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorTwoFactorEnabled {
+			errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	enrolment := models.TwoFactorEnrolment{
+		Secret: secret,
+		URI:    utils.TOTPURI(m.App.Config.Feed.Title, user.Email, secret),
+	}
+
+	err = writeJSON(w, http.StatusOK, enrolment)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// ConfirmTwoFactor is the handler for finishing the two-factor enrolment of
+// the current user with a first code. It returns the recovery codes, which
+// are not shown again.
+func (m *Repository) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request models.TwoFactorCodeRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	user, ok := m.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.TwoFactor == nil || user.TwoFactor.PendingSecret == "" {
+		errorJSON(w, errors.New(dbrepo.ErrorNoTwoFactorEnrolment))
+		return
+	}
+
+	step, valid := utils.VerifyTOTP(user.TwoFactor.PendingSecret, normalizeCode(request.Code), time.Now())
+	if !valid {
+		errorJSON(w, errInvalidTwoFactorCode)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.DB.EnableTwoFactor(user.ID, user.TwoFactor.PendingSecret, step, hashes)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorNoTwoFactorEnrolment {
+			errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	type jsonResp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	err = writeJSON(w, http.StatusOK, jsonResp{RecoveryCodes: codes})
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// DisableTwoFactor is the handler for removing the two-factor authentication
+// of the current user with a valid code. Users whose roles need two-factor
+// authentication can't remove it.
+func (m *Repository) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request models.TwoFactorCodeRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	user, ok := m.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !user.TwoFactorEnabled() {
+		errorJSON(w, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	twoFactorRoles, err := m.DB.GetTwoFactorRoles()
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, role := range user.Roles {
+		if containsString(twoFactorRoles, role) {
+			err = errors.New("two-factor authentication is required for the role " + role)
+			errorJSON(w, err, http.StatusForbidden)
+			return
+		}
+	}
+
+	err = m.checkTwoFactorCode(user, request.Code)
+	if err != nil {
+		if err == errInvalidTwoFactorCode {
+			errorJSON(w, err)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.DB.DisableTwoFactor(user.ID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// LoginTwoFactor is the handler for the second login step. It exchanges the
+// challenge token of the first step and a TOTP or recovery code for a
+// session.
+func (m *Repository) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request models.TwoFactorLoginRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	claims, err := utils.ParseSignedToken(m.App.Config.JWT, tokenPurposeTwoFactorLogin, request.ChallengeToken)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	err = m.DB.AttemptTwoFactorChallenge(claims.Subject, claims.Id, twoFactorMaxAttempts)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorInvalidToken {
+			errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	user, err := m.DB.GetUserById(claims.Subject)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	err = m.canLogIn(user)
+	if err != nil {
+		errorJSON(w, err, http.StatusForbidden)
+		return
+	}
+
+	err = m.checkTwoFactorCode(user, request.Code)
+	if err != nil {
+		if err == errInvalidTwoFactorCode {
+			errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.DB.EndTwoFactorChallenge(user.ID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := m.startSession(r, user.ID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.writeSession(w, user.ID, tokens, request.ReturnToken)
+}
+
+// AdminGetTwoFactorRoles is the handler for retrieving the roles which need
+// two-factor authentication.
+func (m *Repository) AdminGetTwoFactorRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := m.DB.GetTwoFactorRoles()
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, models.TwoFactorRolesRequest{Roles: roles})
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminSetTwoFactorRoles is the handler for choosing the roles which need
+// two-factor authentication. Users without it lose these roles until they
+// enable it.
+func (m *Repository) AdminSetTwoFactorRoles(w http.ResponseWriter, r *http.Request) {
+	var request models.TwoFactorRolesRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.DB.SetTwoFactorRoles(request.Roles)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	m.audit(r, auditActionSetTwoFactorRoles, "two_factor", map[string]interface{}{"roles": request.Roles})
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// AdminDisableTwoFactor is the handler for removing the two-factor
+// authentication of a user who lost its device and recovery codes.
+func (m *Repository) AdminDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	err := m.DB.DisableTwoFactor(id)
+	if err != nil {
+		m.adminUpdateError(w, err)
+		return
+	}
+
+	m.audit(r, auditActionDisableTwoFactor, id, nil)
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// startTwoFactorChallenge answers the first login step of a user with
+// two-factor authentication with a short-lived challenge token.
+func (m *Repository) startTwoFactorChallenge(w http.ResponseWriter, user *models.User) {
+	challengeID, err := utils.RandomToken(16)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = m.DB.StartTwoFactorChallenge(user.ID, challengeID)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	token, err := utils.CreateSignedToken(m.App.Config.JWT, tokenPurposeTwoFactorLogin, user.ID, challengeID, twoFactorChallengeTTL)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusAccepted, twoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         time.Now().Add(twoFactorChallengeTTL),
+	})
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// checkTwoFactorCode checks a TOTP code, or else uses up a recovery code, of
+// the user. Every code can only be used once.
+// Returns errInvalidTwoFactorCode if the code is not valid or another error
+// if any occurred.
+func (m *Repository) checkTwoFactorCode(user *models.User, code string) error {
+	code = normalizeCode(code)
+
+	if len(code) == utils.TOTP_DIGITS {
+		step, valid := utils.VerifyTOTP(user.TwoFactor.Secret, code, time.Now())
+		if !valid {
+			return errInvalidTwoFactorCode
+		}
+
+		err := m.DB.UseTOTPStep(user.ID, step)
+		if err != nil && err.Error() == dbrepo.ErrorInvalidToken {
+			return errInvalidTwoFactorCode
+		}
+		return err
+	}
+
+	err := m.DB.UseRecoveryCode(user.ID, utils.HashToken(code))
+	if err != nil && err.Error() == dbrepo.ErrorInvalidToken {
+		return errInvalidTwoFactorCode
+	}
+	return err
+}
+
+// currentUser fetches the user of the request and answers with an error if it
+// can't.
+// Returns the user and whether it was found.
+func (m *Repository) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user, err := m.DB.GetUserById(userID)
+	if err != nil {
+		errorJSON(w, err, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// newRecoveryCodes creates the recovery codes of a two-factor enrolment.
+// Returns the codes, their hashes and an error if any occurred.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		token, err := utils.RandomToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes[i] = token[:5] + "-" + token[5:]
+		hashes[i] = utils.HashToken(normalizeCode(codes[i]))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeCode removes the spaces and dashes users type into codes.
+func normalizeCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ReplaceAll(code, "-", "")
+}
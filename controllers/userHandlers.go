@@ -3,27 +3,30 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-playground/validator/v10"
 	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
 	"github.com/schattenbrot/mini-blog-api/models"
 	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // InsertUser is the handler for inserting a user into the database.
 func (m *Repository) InsertUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
+	var request models.UserRequest
 
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		errorJSON(w, err)
 		return
 	}
 
+	user := request.ToUser()
 	user.CreatedAt = time.Now()
 	user.Roles = []string{"user"}
 
@@ -54,12 +57,26 @@ func (m *Repository) InsertUser(w http.ResponseWriter, r *http.Request) {
 
 	user.Roles = []string{"user"}
 
-	_, err = m.DB.InsertUser(user)
+	user.EmailVerificationID, err = m.newVerificationID()
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
+	id, err := m.DB.InsertUser(user)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// the user can ask for a new token if sending this one fails
+	if user.EmailVerificationID != "" {
+		err = m.mailVerification(*id, user.Email, user.EmailVerificationID)
+		if err != nil {
+			m.App.Logger.Println("could not send verification mail:", err)
+		}
+	}
+
 	type jsonResp struct {
 		OK bool `json:"ok"`
 	}
@@ -92,31 +109,105 @@ func (m *Repository) GetUserById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = writeJSON(w, http.StatusOK, user)
+	err = writeJSON(w, http.StatusOK, m.userResponses(r, user)[0])
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetUserProfile is the handler for retrieving the public profile of a user by
+// its ID.
+func (m *Repository) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	profile, err := m.DB.GetUserProfile(id)
+	if err != nil {
+		if err.Error() == "the provided hex string is not a valid ObjectID" {
+			errorJSON(w, err)
+			return
+		}
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, profile)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetUserPosts is the handler for retrieving the published posts of a user by
+// page number and page limit.
+func (m *Repository) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	page, limit, err := paginationFromQuery(r)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	_, err = m.DB.GetUserProfile(id)
+	if err != nil {
+		if err.Error() == "the provided hex string is not a valid ObjectID" {
+			errorJSON(w, err)
+			return
+		}
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	filter := postFilterFromQuery(r)
+	filter.Creator = id
+
+	posts, err := m.DB.GetPostsByPage(page, limit, filter)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = renderPosts(posts...)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, posts)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
 	}
 }
 
 // UpdateUserById is the handler for updating a user in database by its ID.
-// The body of the update needs either the name, email, password, or user-roles.
+// The body of the update needs either the name, email, password or profile.
 func (m *Repository) UpdateUserById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	var request models.UserRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		errorJSON(w, err)
 		return
 	}
+	user := request.ToUser()
 	user.ID = id
 
-	v := validator.New()
-	err = v.Struct(user)
+	err = m.App.Validator.Struct(user)
 	if err != nil {
 		errorJSON(w, err)
 		return
 	}
+	if user.Avatar != "" && !m.mediaExist(w, r, []string{user.Avatar}, id) {
+		return
+	}
 	if user.Password != "" {
 		passwordValid := utils.PasswordIsValid(user.Password)
 		if !passwordValid {
@@ -124,6 +215,23 @@ func (m *Repository) UpdateUserById(w http.ResponseWriter, r *http.Request) {
 			errorJSON(w, err)
 			return
 		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
+		if err != nil {
+			errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+		user.Password = string(hashedPassword)
+	}
+
+	current, err := m.DB.GetUserById(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
 	}
 
 	err = m.DB.UpdateUser(user)
@@ -139,6 +247,14 @@ func (m *Repository) UpdateUserById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a changed email has to be verified again
+	if user.Email != "" && user.Email != current.Email {
+		err = m.sendVerification(id, user.Email)
+		if err != nil {
+			m.App.Logger.Println("could not send verification mail:", err)
+		}
+	}
+
 	err = writeJSON(w, http.StatusNoContent)
 	if err != nil {
 		errorJSON(w, err, http.StatusInternalServerError)
@@ -146,13 +262,35 @@ func (m *Repository) UpdateUserById(w http.ResponseWriter, r *http.Request) {
 }
 
 // DeleteUser is the handler for deleting a user from the database by its ID.
+// The query parameter "posts" chooses the policy for the posts of the user
+// out of the configured policies. It defaults to the first configured policy.
 func (m *Repository) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	err := m.DB.DeleteUser(id)
+	policies := m.App.Config.UserDeletion.Policies
+	policy := r.URL.Query().Get("posts")
+	if policy == "" {
+		policy = policies[0]
+	}
+	if !containsString(policies, policy) {
+		err := fmt.Errorf("user deletion policy must be one of %s", strings.Join(policies, ", "))
+		errorJSON(w, err)
+		return
+	}
+
+	err := m.DB.DeleteUser(id, policy)
 	if err != nil {
 		if err.Error() == dbrepo.ErrorDocumentNotFound {
 			errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		if err.Error() == dbrepo.ErrorUserHasPosts {
+			errorJSON(w, err, http.StatusConflict)
+			return
+		}
+		if err.Error() == dbrepo.ErrorGhostUserDeletion {
+			errorJSON(w, err, http.StatusForbidden)
+			return
 		}
 		errorJSON(w, err, http.StatusInternalServerError)
 		return
@@ -163,3 +301,19 @@ func (m *Repository) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		errorJSON(w, err, http.StatusInternalServerError)
 	}
 }
+
+// userResponses creates the responses for the given users. The email and
+// suspension of a user are only visible to the user itself and to users
+// allowed to read private user data.
+func (m *Repository) userResponses(r *http.Request, users ...*models.User) []models.UserResponse {
+	auth, ok := utils.GetAuthentication(r)
+	admin := m.can(r, models.PermUserReadPrivate)
+
+	responses := []models.UserResponse{}
+	for _, user := range users {
+		showPrivate := admin || (ok && auth.UserID == user.ID)
+		responses = append(responses, models.NewUserResponse(user, showPrivate))
+	}
+
+	return responses
+}
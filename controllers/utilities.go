@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"net"
 	"net/http"
 )
 
@@ -25,6 +27,22 @@ func writeJSON(w http.ResponseWriter, status int, data ...interface{}) error {
 	return nil
 }
 
+// writeXML is the helper function for sending back an XML response with the
+// given content type.
+func writeXML(w http.ResponseWriter, status int, contentType string, data interface{}) error {
+	xmlData, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(xmlData)
+
+	return nil
+}
+
 // errorJSON is the helper function for creating an error message.
 // This internally then runs the writeJSON function to send the HTTP response.
 func errorJSON(w http.ResponseWriter, err error, status ...int) {
@@ -44,3 +62,22 @@ func errorJSON(w http.ResponseWriter, err error, status ...int) {
 
 	writeJSON(w, statusCode, theError)
 }
+
+// containsString checks if the list contains the given string.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address the request was sent from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/database/dbrepo"
+	"github.com/schattenbrot/mini-blog-api/mailer"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// tokenPurposeVerifyEmail is the purpose of the signed email verification
+// tokens.
+const tokenPurposeVerifyEmail = "verify_email"
+
+// verificationTokenTTL is how long an email verification token stays valid.
+const verificationTokenTTL = 48 * time.Hour
+
+// VerifyEmail is the handler for confirming the email of a user with the
+// token sent to it.
+func (m *Repository) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var request models.TokenRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	claims, err := utils.ParseSignedToken(m.App.Config.JWT, tokenPurposeVerifyEmail, request.Token)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.DB.VerifyEmail(claims.Subject, claims.Id)
+	if err != nil {
+		if err.Error() == dbrepo.ErrorInvalidToken {
+			errorJSON(w, err)
+			return
+		}
+		errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = writeJSON(w, http.StatusNoContent)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// ResendVerification is the handler for sending a new verification token to
+// the email of an unverified user. It always answers the same way and right
+// away, since the user is looked up and mailed in the background, so it
+// cannot be used to find out which emails are registered.
+func (m *Repository) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var request models.EmailRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	err = m.App.Validator.Struct(request)
+	if err != nil {
+		errorJSON(w, err)
+		return
+	}
+
+	go m.resendVerification(request.Email)
+
+	type jsonResp struct {
+		OK bool `json:"ok"`
+	}
+
+	response := jsonResp{
+		OK: true,
+	}
+
+	err = writeJSON(w, http.StatusAccepted, response)
+	if err != nil {
+		errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+// resendVerification sends a new verification token if the email belongs to
+// an unverified user. Errors are only logged, since nobody waits for them.
+func (m *Repository) resendVerification(email string) {
+	user, err := m.DB.GetUserByEmail(email)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			m.App.Logger.Println("could not look up user for verification mail:", err)
+		}
+		return
+	}
+	if user.EmailVerified() {
+		return
+	}
+
+	err = m.sendVerification(user.ID, user.Email)
+	if err != nil {
+		m.App.Logger.Println("could not send verification mail:", err)
+	}
+}
+
+// newVerificationID creates the ID of a new verification token if email
+// verification is enabled.
+// Returns the ID, which is empty if verification is off, and an error if any
+// occurred.
+func (m *Repository) newVerificationID() (string, error) {
+	if m.App.Config.EmailVerification == models.EmailVerificationOff {
+		return "", nil
+	}
+
+	return utils.RandomToken(16)
+}
+
+// sendVerification creates a new verification token for the user, which
+// replaces all previous tokens, and mails it to the given email.
+// Returns an error if any occurred.
+func (m *Repository) sendVerification(userID, email string) error {
+	tokenID, err := m.newVerificationID()
+	if err != nil || tokenID == "" {
+		return err
+	}
+
+	err = m.DB.SetEmailVerification(userID, tokenID)
+	if err != nil {
+		return err
+	}
+
+	return m.mailVerification(userID, email, tokenID)
+}
+
+// mailVerification mails the signed verification token with the given ID to
+// the user.
+// Returns an error if any occurred.
+func (m *Repository) mailVerification(userID, email, tokenID string) error {
+	token, err := utils.CreateSignedToken(m.App.Config.JWT, tokenPurposeVerifyEmail, userID, tokenID, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return m.Mailer.Send(mailer.Mail{
+		To:      email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Please verify your email by sending this token to %s/v1/users/verify:\n\n%s\n\nThe token expires in %s.",
+			m.App.Config.Site.URL, token, verificationTokenTTL),
+	})
+}
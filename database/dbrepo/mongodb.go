@@ -8,32 +8,56 @@ import (
 	"github.com/schattenbrot/mini-blog-api/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var ErrorTitleAndTextEmpty = "title and text cannot be empty"
+var ErrorTitleAndTextEmpty = "title, text, status, tags, category and media cannot be empty"
 var ErrorDocumentNotFound = "document not found"
 var ErrorAlreadyUpToDate = "up to date"
-var ErrorNameEmailPasswordEmpty = "either name or email or password cannot be empty"
+var ErrorConcurrentUpdate = "document was changed concurrently"
+var ErrorNameEmailPasswordEmpty = "either name or email or password or profile cannot be empty"
 
 // Post is the Post type used for communication with the mongo driver.
 type Post struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Title     string             `bson:"title,omitempty"`
-	Text      string             `bson:"text,omitempty"`
-	Creator   string             `bson:"creator,omitempty"`
-	CreatedAt time.Time          `bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `bson:"updated_at,omitempty"`
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Slug        string             `bson:"slug,omitempty"`
+	SlugAliases []string           `bson:"slug_aliases,omitempty"`
+	Text        string             `bson:"text,omitempty"`
+	Creator     string             `bson:"creator,omitempty"`
+	Tags        []string           `bson:"tags,omitempty"`
+	Media       []string           `bson:"media,omitempty"`
+	Category    string             `bson:"category,omitempty"`
+	Status      string             `bson:"status,omitempty"`
+	PublishAt   time.Time          `bson:"publish_at,omitempty"`
+	Revision    int                `bson:"revision,omitempty"`
+	Comments    int                `bson:"comment_count,omitempty"`
+	Reactions   map[string]int     `bson:"reactions,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at,omitempty"`
+	UpdatedAt   time.Time          `bson:"updated_at,omitempty"`
+	DeletedAt   time.Time          `bson:"deleted_at,omitempty"`
 }
 
 // User is the User type used for communication with the mongo driver.
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Name      string             `bson:"name,omitempty"`
-	Email     string             `bson:"email,omitempty" validate:"omitempty,email"`
-	Password  string             `bson:"password,omitempty"`
-	Roles     []string           `bson:"roles,omitempty"`
-	CreatedAt time.Time          `bson:"created_at,omitempty"`
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	Name            string             `bson:"name,omitempty"`
+	Email           string             `bson:"email,omitempty" validate:"omitempty,email"`
+	Password        string             `bson:"password,omitempty"`
+	Roles           []string           `bson:"roles,omitempty"`
+	DisplayName     string             `bson:"display_name,omitempty"`
+	Bio             string             `bson:"bio,omitempty"`
+	Avatar          string             `bson:"avatar,omitempty"`
+	Links           []string           `bson:"links,omitempty"`
+	Suspension      *Suspension        `bson:"suspension,omitempty"`
+	RevokedAt       time.Time          `bson:"sessions_revoked_at,omitempty"`
+	VerificationID  string             `bson:"email_verification_id,omitempty"`
+	EmailVerifiedAt time.Time          `bson:"email_verified_at,omitempty"`
+	TwoFactor       *TwoFactor         `bson:"two_factor,omitempty"`
+	Identities      []Identity         `bson:"identities,omitempty"`
+	CreatedAt       time.Time          `bson:"created_at,omitempty"`
+	DeletedAt       time.Time          `bson:"deleted_at,omitempty"`
 }
 
 // toModelPost converts a mongoPost to a models.Post.
@@ -41,10 +65,21 @@ func toModelPost(post *Post) models.Post {
 	var modelPost models.Post
 	modelPost.ID = post.ID.Hex()
 	modelPost.Title = post.Title
+	modelPost.Slug = post.Slug
 	modelPost.Text = post.Text
 	modelPost.Creator = post.Creator
+	modelPost.Tags = post.Tags
+	modelPost.Media = post.Media
+	modelPost.Category = post.Category
+	modelPost.Status = post.Status
+	modelPost.PublishAt = post.PublishAt
+	modelPost.Comments = post.Comments
+	modelPost.Reactions = post.Reactions
 	modelPost.CreatedAt = post.CreatedAt
 	modelPost.UpdatedAt = post.UpdatedAt
+	if !post.DeletedAt.IsZero() {
+		modelPost.DeletedAt = &post.DeletedAt
+	}
 
 	return modelPost
 }
@@ -57,11 +92,95 @@ func toModelUser(user *User) models.User {
 	modelUser.Email = user.Email
 	modelUser.Password = user.Password
 	modelUser.Roles = user.Roles
+	modelUser.DisplayName = user.DisplayName
+	modelUser.Bio = user.Bio
+	modelUser.Avatar = user.Avatar
+	modelUser.Links = user.Links
+	if user.Suspension != nil {
+		modelUser.Suspension = &models.Suspension{
+			Reason: user.Suspension.Reason,
+			By:     user.Suspension.By,
+			At:     user.Suspension.At,
+		}
+	}
+	if !user.RevokedAt.IsZero() {
+		modelUser.SessionsRevokedAt = &user.RevokedAt
+	}
+	modelUser.EmailVerificationID = user.VerificationID
+	modelUser.TwoFactor = toModelTwoFactor(user.TwoFactor)
+	for _, identity := range user.Identities {
+		modelUser.Identities = append(modelUser.Identities, models.Identity{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+		})
+	}
 	modelUser.CreatedAt = user.CreatedAt
+	if !user.DeletedAt.IsZero() {
+		modelUser.DeletedAt = &user.DeletedAt
+	}
 
 	return modelUser
 }
 
+// notDeleted returns the condition for the "deleted_at" field matching all
+// documents which are not in the trash.
+func notDeleted() bson.M {
+	return bson.M{"$exists": false}
+}
+
+// publishedFilter returns the filter matching all posts which are visible to
+// the public. Posts without a status predate the post lifecycle and are
+// treated as published.
+func publishedFilter() bson.M {
+	return bson.M{
+		"deleted_at": notDeleted(),
+		"$or": bson.A{
+			bson.M{
+				"status":     models.PostStatusPublished,
+				"publish_at": bson.M{"$lte": time.Now()},
+			},
+			bson.M{"status": bson.M{"$exists": false}},
+		},
+	}
+}
+
+// equalStrings checks if both slices contain the same strings in the same
+// order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// postListFilter returns the filter for the public post listings narrowed
+// down by the given tag, category and creator.
+func postListFilter(f models.PostFilter) bson.M {
+	filter := publishedFilter()
+	if f.Tag != "" {
+		filter["tags"] = f.Tag
+	}
+	if f.Category != "" {
+		filter["category"] = f.Category
+	}
+	if f.Creator != "" {
+		filter["creator"] = f.Creator
+	}
+	return filter
+}
+
+// publishedSort returns the order of the public post listings, the latest
+// published first. Posts from before the post lifecycle have no publish time
+// and follow by their creation time.
+func publishedSort() bson.D {
+	return bson.D{{Key: "publish_at", Value: -1}, {Key: "created_at", Value: -1}}
+}
+
 // InsertPost inserts a given post into the database.
 // Returns the post ID of the inserted post and an error if any occurred.
 func (m *mongoDBRepo) InsertPost(p models.Post) (*string, error) {
@@ -72,18 +191,52 @@ func (m *mongoDBRepo) InsertPost(p models.Post) (*string, error) {
 	post.Title = p.Title
 	post.Text = p.Text
 	post.Creator = p.Creator
+	post.Tags = p.Tags
+	post.Media = p.Media
+	post.Category = p.Category
+	post.Status = p.Status
+	post.PublishAt = p.PublishAt
+	post.Revision = 1
 	post.CreatedAt = p.CreatedAt
 	post.UpdatedAt = p.UpdatedAt
 
 	collection := m.DB.Collection("posts")
 
-	result, err := collection.InsertOne(ctx, post)
+	// the slug could be taken by a concurrent insert between the check and
+	// the insert, so a few attempts are made before giving up
+	var result *mongo.InsertOneResult
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		post.Slug, err = m.uniqueSlug(ctx, post.Title, primitive.NilObjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = collection.InsertOne(ctx, post)
+		if err == nil || !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	oid := result.InsertedID.(primitive.ObjectID).Hex()
 
+	revision := Revision{
+		PostID:    oid,
+		Number:    post.Revision,
+		Title:     post.Title,
+		Text:      post.Text,
+		Editor:    post.Creator,
+		CreatedAt: post.CreatedAt,
+	}
+
+	_, err = m.DB.Collection("revisions").InsertOne(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+
 	return &oid, nil
 }
 
@@ -102,7 +255,7 @@ func (m *mongoDBRepo) GetPostById(id string) (*models.Post, error) {
 
 	collection := m.DB.Collection("posts")
 
-	filter := Post{ID: oid}
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
 
 	err = collection.FindOne(ctx, filter).Decode(&post)
 	if err != nil {
@@ -114,9 +267,10 @@ func (m *mongoDBRepo) GetPostById(id string) (*models.Post, error) {
 	return &modelPost, nil
 }
 
-// GetPosts gets a list of posts from the database.
+// GetPosts gets a list of published posts from the database.
+// The posts can be filtered by tag and category.
 // Returns a list of posts and an error if any occurred.
-func (m *mongoDBRepo) GetPosts() ([]*models.Post, error) {
+func (m *mongoDBRepo) GetPosts(f models.PostFilter) ([]*models.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -124,10 +278,10 @@ func (m *mongoDBRepo) GetPosts() ([]*models.Post, error) {
 
 	collection := m.DB.Collection("posts")
 
-	filter := bson.D{}
+	filter := postListFilter(f)
 
 	opts := options.Find()
-	opts.SetSort(bson.D{{"created_at", -1}})
+	opts.SetSort(publishedSort())
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
@@ -167,7 +321,7 @@ func (m *mongoDBRepo) GetPostCreator(id string) (string, error) {
 	options.SetProjection(proj)
 
 	type Result struct {
-		Creator primitive.ObjectID `bson:"creator"`
+		Creator string `bson:"creator"`
 	}
 	var result Result
 	err = collection.FindOne(ctx, filter, &options).Decode(&result)
@@ -175,12 +329,13 @@ func (m *mongoDBRepo) GetPostCreator(id string) (string, error) {
 		return "", err
 	}
 
-	return result.Creator.Hex(), nil
+	return result.Creator, nil
 }
 
-// GetPostsByPage gets a list of posts by page number and page limit.
+// GetPostsByPage gets a list of published posts by page number and page limit.
+// The posts can be filtered by tag and category.
 // Returns a list of posts and an error if any occurred.
-func (m *mongoDBRepo) GetPostsByPage(page, limit int) ([]*models.Post, error) {
+func (m *mongoDBRepo) GetPostsByPage(page, limit int, f models.PostFilter) ([]*models.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -188,11 +343,11 @@ func (m *mongoDBRepo) GetPostsByPage(page, limit int) ([]*models.Post, error) {
 
 	collection := m.DB.Collection("posts")
 
-	filter := bson.M{}
+	filter := postListFilter(f)
 	findOptions := options.Find()
 	findOptions.SetSkip((int64(page) - 1) * int64(limit))
 	findOptions.SetLimit(int64(limit))
-	findOptions.SetSort(bson.D{{"created_at", -1}})
+	findOptions.SetSort(publishedSort())
 
 	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
@@ -212,53 +367,199 @@ func (m *mongoDBRepo) GetPostsByPage(page, limit int) ([]*models.Post, error) {
 	return posts, nil
 }
 
-// UpdatePost updates a given post in the database.
+// GetDraftsByCreator gets all drafts and scheduled posts of a creator.
+// Returns a list of posts and an error if any occurred.
+func (m *mongoDBRepo) GetDraftsByCreator(creator string) ([]*models.Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	posts := []*models.Post{}
+
+	collection := m.DB.Collection("posts")
+
+	filter := bson.M{
+		"creator":    creator,
+		"deleted_at": notDeleted(),
+		"status": bson.M{"$in": bson.A{
+			models.PostStatusDraft,
+			models.PostStatusScheduled,
+		}},
+	}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post Post
+		cursor.Decode(&post)
+
+		newPost := toModelPost(&post)
+
+		posts = append(posts, &newPost)
+	}
+
+	return posts, nil
+}
+
+// UpdatePost updates a given post in the database. Every change of the title
+// or text is stored as a new revision made by the given editor.
 // Returns an error if any occurred.
-func (m *mongoDBRepo) UpdatePost(p models.Post) error {
+func (m *mongoDBRepo) UpdatePost(p models.Post, editor string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if p.Title == "" && p.Text == "" {
+	if p.Title == "" && p.Text == "" && p.Status == "" && p.Category == "" && p.Tags == nil && p.Media == nil {
 		return errors.New(ErrorTitleAndTextEmpty)
 	}
 
+	oid, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("posts")
+
+	var current Post
+	err = collection.FindOne(ctx, bson.M{"_id": oid, "deleted_at": notDeleted()}).Decode(&current)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New(ErrorDocumentNotFound)
+		}
+		return err
+	}
+
 	var post Post
-	if p.Title != "" {
+	if p.Title != "" && p.Title != current.Title {
 		post.Title = p.Title
 	}
-	if p.Text != "" {
+	if p.Text != "" && p.Text != current.Text {
 		post.Text = p.Text
 	}
-	post.UpdatedAt = time.Now()
+	if p.Status != "" && (p.Status != current.Status || !p.PublishAt.Equal(current.PublishAt)) {
+		post.Status = p.Status
+		post.PublishAt = p.PublishAt
+	}
+	if post.Title != "" || current.Slug == "" {
+		slug, err := m.uniqueSlug(ctx, firstNonEmpty(post.Title, current.Title), oid)
+		if err != nil {
+			return err
+		}
+		// the old slug stays reachable as an alias
+		if slug != current.Slug {
+			post.Slug = slug
+			post.SlugAliases = withoutString(current.SlugAliases, slug)
+			if current.Slug != "" {
+				post.SlugAliases = append(post.SlugAliases, current.Slug)
+			}
+		}
+	}
+	if p.Category != "" && p.Category != current.Category {
+		post.Category = p.Category
+	}
 
-	collection := m.DB.Collection("posts")
+	// an empty list of tags removes all tags of the post
+	clearTags := p.Tags != nil && len(p.Tags) == 0 && len(current.Tags) > 0
+	if len(p.Tags) > 0 && !equalStrings(p.Tags, current.Tags) {
+		post.Tags = p.Tags
+	}
 
-	oid, err := primitive.ObjectIDFromHex(p.ID)
-	if err != nil {
-		return err
+	// an empty list of media removes all media of the post
+	clearMedia := p.Media != nil && len(p.Media) == 0 && len(current.Media) > 0
+	if len(p.Media) > 0 && !equalStrings(p.Media, current.Media) {
+		post.Media = p.Media
+	}
+
+	contentChanged := post.Title != "" || post.Text != ""
+	metaChanged := post.Status != "" || post.Category != "" || post.Tags != nil || clearTags ||
+		post.Media != nil || clearMedia || post.Slug != ""
+	if !contentChanged && !metaChanged {
+		return errors.New(ErrorAlreadyUpToDate)
+	}
+
+	now := time.Now()
+	post.UpdatedAt = now
+
+	// the revision number doubles as a version to detect concurrent edits
+	filter := bson.M{"_id": oid, "revision": current.Revision, "deleted_at": notDeleted()}
+	if current.Revision == 0 {
+		filter["revision"] = bson.M{"$exists": false}
+	}
+
+	// Posts created before revisions existed get their current content
+	// stored as the first revision before it gets overwritten.
+	var baseline *Revision
+	if contentChanged && current.Revision == 0 {
+		baseline = &Revision{
+			PostID:    current.ID.Hex(),
+			Number:    1,
+			Title:     current.Title,
+			Text:      current.Text,
+			Editor:    current.Creator,
+			CreatedAt: current.UpdatedAt,
+		}
+		current.Revision = 1
+	}
+	if contentChanged {
+		post.Revision = current.Revision + 1
 	}
 
 	update := bson.M{"$set": post}
+	unset := bson.M{}
+	if clearTags {
+		unset["tags"] = ""
+	}
+	if clearMedia {
+		unset["media"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
 
-	result, err := collection.UpdateByID(ctx, oid, update)
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New(ErrorConcurrentUpdate)
+		}
 		return err
 	}
 
 	if result.MatchedCount == 0 {
-		err = errors.New(ErrorDocumentNotFound)
+		err = errors.New(ErrorConcurrentUpdate)
 		return err
 	}
 
-	if result.ModifiedCount == 0 {
-		err = errors.New(ErrorAlreadyUpToDate)
+	if !contentChanged {
+		return nil
+	}
+
+	revisions := []interface{}{}
+	if baseline != nil {
+		revisions = append(revisions, baseline)
+	}
+	revisions = append(revisions, Revision{
+		PostID:    current.ID.Hex(),
+		Number:    post.Revision,
+		Title:     firstNonEmpty(post.Title, current.Title),
+		Text:      firstNonEmpty(post.Text, current.Text),
+		Editor:    editor,
+		CreatedAt: now,
+	})
+
+	_, err = m.DB.Collection("revisions").InsertMany(ctx, revisions)
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// DeleteOnePost deletes one post from the database by its ID.
+// DeleteOnePost moves one post to the trash by setting its deletion time.
 // Returns an error if any occurred.
 func (m *mongoDBRepo) DeleteOnePost(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -271,14 +572,15 @@ func (m *mongoDBRepo) DeleteOnePost(id string) error {
 
 	collection := m.DB.Collection("posts")
 
-	filter := Post{ID: oid}
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
 
-	result, err := collection.DeleteOne(ctx, filter)
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		err = errors.New(ErrorDocumentNotFound)
 		return err
 	}
@@ -286,6 +588,90 @@ func (m *mongoDBRepo) DeleteOnePost(id string) error {
 	return nil
 }
 
+// SearchPosts searches the title and text of all published posts using the
+// text index of the posts collection. Title matches are weighted above text
+// matches.
+// Returns a page of results, the most relevant first, and an error if any
+// occurred.
+func (m *mongoDBRepo) SearchPosts(query string, page, limit int) ([]*models.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results := []*models.SearchResult{}
+
+	collection := m.DB.Collection("posts")
+
+	filter := publishedFilter()
+	filter["$text"] = bson.M{"$search": query}
+
+	score := bson.M{"$meta": "textScore"}
+	findOptions := options.Find()
+	findOptions.SetProjection(bson.M{"score": score})
+	findOptions.SetSort(append(bson.D{{Key: "score", Value: score}}, publishedSort()...))
+	findOptions.SetSkip((int64(page) - 1) * int64(limit))
+	findOptions.SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result struct {
+			Post  `bson:",inline"`
+			Score float64 `bson:"score"`
+		}
+		cursor.Decode(&result)
+
+		results = append(results, &models.SearchResult{
+			Post:  toModelPost(&result.Post),
+			Score: result.Score,
+		})
+	}
+
+	return results, nil
+}
+
+// GetTagCounts counts how many published posts use each tag.
+// Returns the tags sorted by their usage and an error if any occurred.
+func (m *mongoDBRepo) GetTagCounts() ([]*models.TagCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tags := []*models.TagCount{}
+
+	collection := m.DB.Collection("posts")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: publishedFilter()}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result struct {
+			Tag   string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		cursor.Decode(&result)
+
+		tags = append(tags, &models.TagCount{
+			Tag:   result.Tag,
+			Count: result.Count,
+		})
+	}
+
+	return tags, nil
+}
+
 // InsertUser inserts a given user into the database.
 // Returns the user ID of the inserted user and an error if any occurred.
 func (m *mongoDBRepo) InsertUser(u models.User) (*string, error) {
@@ -293,11 +679,18 @@ func (m *mongoDBRepo) InsertUser(u models.User) (*string, error) {
 	defer cancel()
 
 	user := User{
-		Name:      u.Name,
-		Email:     u.Email,
-		Password:  u.Password,
-		Roles:     u.Roles,
-		CreatedAt: time.Now(),
+		Name:           u.Name,
+		Email:          u.Email,
+		Password:       u.Password,
+		Roles:          u.Roles,
+		VerificationID: u.EmailVerificationID,
+		CreatedAt:      time.Now(),
+	}
+	for _, identity := range u.Identities {
+		user.Identities = append(user.Identities, Identity{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+		})
 	}
 
 	collection := m.DB.Collection("users")
@@ -325,7 +718,7 @@ func (m *mongoDBRepo) GetUserById(id string) (*models.User, error) {
 		return nil, err
 	}
 
-	filter := User{ID: oid}
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
 
 	collection := m.DB.Collection("users")
 
@@ -339,7 +732,9 @@ func (m *mongoDBRepo) GetUserById(id string) (*models.User, error) {
 	return &fetchedUser, nil
 }
 
-// GetUserRoles fetches the roles of a user from the database.
+// GetUserRoles fetches the roles of a user from the database. Users with an
+// unverified email only get the unverified role, users without two-factor
+// authentication don't get the roles which need it.
 // Returns the user's roles and an error if any occurred.
 func (m *mongoDBRepo) GetUserRoles(id string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -352,22 +747,51 @@ func (m *mongoDBRepo) GetUserRoles(id string) ([]string, error) {
 
 	collection := m.DB.Collection("users")
 
-	filter := User{ID: oid}
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
 
-	proj := bson.M{"roles": 1}
+	proj := bson.M{"roles": 1, "email_verification_id": 1, "two_factor.enabled_at": 1}
 	var options options.FindOneOptions
 	options.SetProjection(proj)
 
 	type Result struct {
-		Roles []string `bson:"roles"`
+		Roles          []string `bson:"roles"`
+		VerificationID string   `bson:"email_verification_id"`
+		TwoFactor      struct {
+			EnabledAt time.Time `bson:"enabled_at"`
+		} `bson:"two_factor"`
 	}
 	var result Result
 
-	err = collection.FindOne(ctx, filter).Decode(&result)
+	err = collection.FindOne(ctx, filter, &options).Decode(&result)
 	if err != nil {
 		return nil, err
 	}
 
+	// unverified users only get the permissions of the unverified role
+	if result.VerificationID != "" {
+		return []string{models.RoleUnverified}, nil
+	}
+
+	if result.TwoFactor.EnabledAt.IsZero() {
+		twoFactorRoles, err := m.getTwoFactorRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		needsTwoFactor := map[string]bool{}
+		for _, role := range twoFactorRoles {
+			needsTwoFactor[role] = true
+		}
+
+		roles := []string{}
+		for _, role := range result.Roles {
+			if !needsTwoFactor[role] {
+				roles = append(roles, role)
+			}
+		}
+		return roles, nil
+	}
+
 	return result.Roles, err
 }
 
@@ -379,7 +803,7 @@ func (m *mongoDBRepo) GetUserByEmail(email string) (*models.User, error) {
 
 	var user User
 
-	filter := User{Email: email}
+	filter := bson.M{"email": email, "deleted_at": notDeleted()}
 
 	collection := m.DB.Collection("users")
 
@@ -399,7 +823,8 @@ func (m *mongoDBRepo) UpdateUser(u models.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if u.Name == "" && u.Email == "" && u.Password == "" {
+	if u.Name == "" && u.Email == "" && u.Password == "" && u.DisplayName == "" &&
+		u.Bio == "" && u.Avatar == "" && u.Links == nil {
 		return errors.New(ErrorNameEmailPasswordEmpty)
 	}
 
@@ -413,6 +838,10 @@ func (m *mongoDBRepo) UpdateUser(u models.User) error {
 	if u.Password != "" {
 		user.Password = u.Password
 	}
+	user.DisplayName = u.DisplayName
+	user.Bio = u.Bio
+	user.Avatar = u.Avatar
+	user.Links = u.Links
 
 	collection := m.DB.Collection("users")
 
@@ -421,9 +850,10 @@ func (m *mongoDBRepo) UpdateUser(u models.User) error {
 		return err
 	}
 
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
 	update := bson.M{"$set": user}
 
-	result, err := collection.UpdateByID(ctx, oid, update)
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
@@ -440,31 +870,3 @@ func (m *mongoDBRepo) UpdateUser(u models.User) error {
 
 	return nil
 }
-
-// DeleteUser deletes a user from the database by its ID.
-// Returns an error if any occurred.
-func (m *mongoDBRepo) DeleteUser(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	oid, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
-
-	collection := m.DB.Collection("users")
-
-	filter := User{ID: oid}
-
-	result, err := collection.DeleteOne(ctx, filter)
-	if err != nil {
-		return err
-	}
-
-	if result.DeletedCount == 0 {
-		err = errors.New(ErrorDocumentNotFound)
-		return err
-	}
-
-	return nil
-}
@@ -0,0 +1,156 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKey is the APIKey type used for communication with the mongo driver.
+// Only the hash of the key is stored.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	UserID     string             `bson:"user_id,omitempty"`
+	Name       string             `bson:"name,omitempty"`
+	Hint       string             `bson:"hint,omitempty"`
+	Hash       string             `bson:"hash,omitempty"`
+	Scopes     []string           `bson:"scopes,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty"`
+}
+
+// toModelAPIKey converts a mongoAPIKey to a models.APIKey.
+func toModelAPIKey(key *APIKey) models.APIKey {
+	var modelKey models.APIKey
+	modelKey.ID = key.ID.Hex()
+	modelKey.UserID = key.UserID
+	modelKey.Name = key.Name
+	modelKey.Hint = key.Hint
+	modelKey.Scopes = key.Scopes
+	modelKey.CreatedAt = key.CreatedAt
+	modelKey.LastUsedAt = key.LastUsedAt
+	modelKey.ExpiresAt = key.ExpiresAt
+	return modelKey
+}
+
+// unexpiredAPIKey returns the filter for keys which have no or a future
+// expiry.
+func unexpiredAPIKey(now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"expires_at": bson.M{"$exists": false}},
+		{"expires_at": bson.M{"$gt": now}},
+	}}
+}
+
+// InsertAPIKey stores a new personal API key by its hash.
+// Returns the ID of the key and an error if any occurred.
+func (m *mongoDBRepo) InsertAPIKey(k models.APIKey, hash string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := APIKey{
+		UserID:    k.UserID,
+		Name:      k.Name,
+		Hint:      k.Hint,
+		Hash:      hash,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+	}
+
+	result, err := m.DB.Collection("api_keys").InsertOne(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// UseAPIKey fetches the unexpired key with the given hash and records its
+// use.
+// Returns the key and an error if any occurred.
+func (m *mongoDBRepo) UseAPIKey(hash string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	filter := unexpiredAPIKey(now)
+	filter["hash"] = hash
+	update := bson.M{"$set": bson.M{"last_used_at": now}}
+
+	var key APIKey
+	err := m.DB.Collection("api_keys").FindOneAndUpdate(ctx, filter, update).Decode(&key)
+	if err != nil {
+		return nil, err
+	}
+
+	usedKey := toModelAPIKey(&key)
+
+	return &usedKey, nil
+}
+
+// GetAPIKeys fetches all unexpired keys of a user, the newest first.
+// Returns a list of keys and an error if any occurred.
+func (m *mongoDBRepo) GetAPIKeys(userID string) ([]*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keys := []*models.APIKey{}
+
+	collection := m.DB.Collection("api_keys")
+
+	filter := unexpiredAPIKey(time.Now())
+	filter["user_id"] = userID
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var key APIKey
+		cursor.Decode(&key)
+
+		newKey := toModelAPIKey(&key)
+
+		keys = append(keys, &newKey)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey revokes a key of the user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) DeleteAPIKey(userID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": oid, "user_id": userID}
+
+	result, err := m.DB.Collection("api_keys").DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New(ErrorDocumentNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Suspension is the Suspension type used for communication with the mongo
+// driver.
+type Suspension struct {
+	Reason string    `bson:"reason,omitempty"`
+	By     string    `bson:"by,omitempty"`
+	At     time.Time `bson:"at,omitempty"`
+}
+
+// AuditEntry is the AuditEntry type used for communication with the mongo
+// driver.
+type AuditEntry struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty"`
+	Actor     string                 `bson:"actor,omitempty"`
+	Action    string                 `bson:"action,omitempty"`
+	Target    string                 `bson:"target,omitempty"`
+	Details   map[string]interface{} `bson:"details,omitempty"`
+	CreatedAt time.Time              `bson:"created_at,omitempty"`
+}
+
+// toModelAuditEntry converts a mongoAuditEntry to a models.AuditEntry.
+func toModelAuditEntry(entry *AuditEntry) models.AuditEntry {
+	var modelEntry models.AuditEntry
+	modelEntry.ID = entry.ID.Hex()
+	modelEntry.Actor = entry.Actor
+	modelEntry.Action = entry.Action
+	modelEntry.Target = entry.Target
+	modelEntry.Details = entry.Details
+	modelEntry.CreatedAt = entry.CreatedAt
+
+	return modelEntry
+}
+
+// GetUsers gets a page of all users, the newest first. If a query is given
+// only users whose name or email contain it are returned.
+// Returns a list of users and an error if any occurred.
+func (m *mongoDBRepo) GetUsers(query string, page, limit int) ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	users := []*models.User{}
+
+	collection := m.DB.Collection("users")
+
+	filter := bson.M{"deleted_at": notDeleted()}
+	if query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"name": pattern},
+			bson.M{"email": pattern},
+		}
+	}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	opts.SetSkip((int64(page) - 1) * int64(limit))
+	opts.SetLimit(int64(limit))
+	opts.SetProjection(bson.M{"password": 0})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user User
+		cursor.Decode(&user)
+
+		newUser := toModelUser(&user)
+
+		users = append(users, &newUser)
+	}
+
+	return users, nil
+}
+
+// SetUserRoles replaces the roles of a user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) SetUserRoles(id string, roles []string) error {
+	return m.updateUserFields(id, bson.M{"$set": bson.M{"roles": roles}})
+}
+
+// SuspendUser suspends a user and revokes all of its sessions.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) SuspendUser(id string, s models.Suspension) error {
+	suspension := Suspension{
+		Reason: s.Reason,
+		By:     s.By,
+		At:     s.At,
+	}
+
+	err := m.updateUserFields(id, bson.M{"$set": bson.M{
+		"suspension":          suspension,
+		"sessions_revoked_at": s.At,
+	}})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.revokeAllSessions(ctx, id, s.At)
+}
+
+// UnsuspendUser lifts the suspension of a user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) UnsuspendUser(id string) error {
+	return m.updateUserFields(id, bson.M{"$unset": bson.M{"suspension": ""}})
+}
+
+// RevokeSessions invalidates all sessions of a user which were started
+// before now.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) RevokeSessions(id string) error {
+	now := time.Now()
+
+	err := m.updateUserFields(id, bson.M{"$set": bson.M{"sessions_revoked_at": now}})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.revokeAllSessions(ctx, id, now)
+}
+
+// updateUserFields applies the update to a user which is not in the trash.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) updateUserFields(id string, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("users")
+
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
+
+// InsertAuditEntry records an action of an admin.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry := AuditEntry{
+		Actor:     e.Actor,
+		Action:    e.Action,
+		Target:    e.Target,
+		Details:   e.Details,
+		CreatedAt: e.CreatedAt,
+	}
+
+	_, err := m.DB.Collection("audit_log").InsertOne(ctx, entry)
+
+	return err
+}
+
+// GetAuditEntries gets a page of the recorded admin actions, the newest
+// first.
+// Returns a list of audit entries and an error if any occurred.
+func (m *mongoDBRepo) GetAuditEntries(page, limit int) ([]*models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entries := []*models.AuditEntry{}
+
+	collection := m.DB.Collection("audit_log")
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	opts.SetSkip((int64(page) - 1) * int64(limit))
+	opts.SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry AuditEntry
+		cursor.Decode(&entry)
+
+		newEntry := toModelAuditEntry(&entry)
+
+		entries = append(entries, &newEntry)
+	}
+
+	return entries, nil
+}
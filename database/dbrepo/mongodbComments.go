@@ -0,0 +1,234 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrorParentNotFound = "parent comment not found"
+
+// Comment is the Comment type used for communication with the mongo driver.
+type Comment struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	PostID    string             `bson:"post_id,omitempty"`
+	ParentID  string             `bson:"parent_id,omitempty"`
+	Ancestors []string           `bson:"ancestors,omitempty"`
+	Author    string             `bson:"author,omitempty"`
+	Text      string             `bson:"text,omitempty"`
+	CreatedAt time.Time          `bson:"created_at,omitempty"`
+	UpdatedAt time.Time          `bson:"updated_at,omitempty"`
+}
+
+// toModelComment converts a mongoComment to a models.Comment.
+func toModelComment(comment *Comment) models.Comment {
+	var modelComment models.Comment
+	modelComment.ID = comment.ID.Hex()
+	modelComment.PostID = comment.PostID
+	modelComment.ParentID = comment.ParentID
+	modelComment.Author = comment.Author
+	modelComment.Text = comment.Text
+	modelComment.CreatedAt = comment.CreatedAt
+	modelComment.UpdatedAt = comment.UpdatedAt
+
+	return modelComment
+}
+
+// InsertComment inserts a given comment into the database and increases the
+// comment count of its post. Replies need a parent comment on the same post.
+// Returns the comment ID of the inserted comment and an error if any occurred.
+func (m *mongoDBRepo) InsertComment(c models.Comment) (*string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	postOID, err := primitive.ObjectIDFromHex(c.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := m.DB.Collection("comments")
+
+	comment := Comment{
+		PostID:    c.PostID,
+		Author:    c.Author,
+		Text:      c.Text,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+
+	if c.ParentID != "" {
+		parentOID, err := primitive.ObjectIDFromHex(c.ParentID)
+		if err != nil {
+			return nil, err
+		}
+
+		var parent Comment
+		err = collection.FindOne(ctx, Comment{ID: parentOID, PostID: c.PostID}).Decode(&parent)
+		if err != nil {
+			return nil, errors.New(ErrorParentNotFound)
+		}
+
+		comment.ParentID = c.ParentID
+		comment.Ancestors = append(parent.Ancestors, c.ParentID)
+	}
+
+	result, err := collection.InsertOne(ctx, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	update := bson.M{"$inc": bson.M{"comment_count": 1}}
+
+	_, err = m.DB.Collection("posts").UpdateByID(ctx, postOID, update)
+	if err != nil {
+		return nil, err
+	}
+
+	oid := result.InsertedID.(primitive.ObjectID).Hex()
+
+	return &oid, nil
+}
+
+// GetCommentsByPost gets all comments of a post, the oldest first.
+// Returns a flat list of comments and an error if any occurred.
+func (m *mongoDBRepo) GetCommentsByPost(postID string) ([]*models.Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comments := []*models.Comment{}
+
+	collection := m.DB.Collection("comments")
+
+	filter := Comment{PostID: postID}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var comment Comment
+		cursor.Decode(&comment)
+
+		newComment := toModelComment(&comment)
+
+		comments = append(comments, &newComment)
+	}
+
+	return comments, nil
+}
+
+// GetCommentAuthor fetches the author of a comment from the database.
+// Returns the author's id and an error if any occurred.
+func (m *mongoDBRepo) GetCommentAuthor(id string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", err
+	}
+
+	collection := m.DB.Collection("comments")
+
+	filter := Comment{ID: oid}
+
+	var opts options.FindOneOptions
+	opts.SetProjection(bson.M{"author": 1})
+
+	var comment Comment
+	err = collection.FindOne(ctx, filter, &opts).Decode(&comment)
+	if err != nil {
+		return "", err
+	}
+
+	return comment.Author, nil
+}
+
+// UpdateComment updates the text of a comment of a post.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) UpdateComment(c models.Comment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("comments")
+
+	filter := Comment{ID: oid, PostID: c.PostID}
+
+	update := bson.M{"$set": Comment{
+		Text:      c.Text,
+		UpdatedAt: time.Now(),
+	}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteComment deletes a comment of a post together with all of its replies
+// and decreases the comment count of the post.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) DeleteComment(postID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	postOID, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("comments")
+
+	filter := bson.M{
+		"post_id": postID,
+		"$or": bson.A{
+			bson.M{"_id": oid},
+			bson.M{"ancestors": id},
+		},
+	}
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	update := bson.M{"$inc": bson.M{"comment_count": -result.DeletedCount}}
+
+	_, err = m.DB.Collection("posts").UpdateByID(ctx, postOID, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
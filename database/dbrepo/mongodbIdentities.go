@@ -0,0 +1,110 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Identity is the Identity type used for communication with the mongo
+// driver.
+type Identity struct {
+	Provider string `bson:"provider,omitempty"`
+	Subject  string `bson:"subject,omitempty"`
+}
+
+// OIDCState is the OIDCState type used for communication with the mongo
+// driver. The state itself is the ID.
+type OIDCState struct {
+	State     string    `bson:"_id,omitempty"`
+	Provider  string    `bson:"provider,omitempty"`
+	Nonce     string    `bson:"nonce,omitempty"`
+	Verifier  string    `bson:"verifier,omitempty"`
+	ExpiresAt time.Time `bson:"expires_at,omitempty"`
+}
+
+// GetUserByIdentity retrieves the user linked to the identity of an external
+// provider.
+// Returns a user and an error if any occurred.
+func (m *mongoDBRepo) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"identities": bson.M{"$elemMatch": Identity{Provider: provider, Subject: subject}},
+		"deleted_at": notDeleted(),
+	}
+
+	var user User
+	err := m.DB.Collection("users").FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedUser := toModelUser(&user)
+
+	return &fetchedUser, nil
+}
+
+// LinkIdentity links the identity of an external provider to a user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) LinkIdentity(userID, provider, subject string) error {
+	identity := Identity{Provider: provider, Subject: subject}
+
+	return m.updateUserFields(userID, bson.M{"$addToSet": bson.M{"identities": identity}})
+}
+
+// InsertOIDCState stores the state of a started login at an external
+// provider.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) InsertOIDCState(s models.OIDCState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	state := OIDCState{
+		State:     s.State,
+		Provider:  s.Provider,
+		Nonce:     s.Nonce,
+		Verifier:  s.Verifier,
+		ExpiresAt: s.ExpiresAt,
+	}
+
+	_, err := m.DB.Collection("oidc_states").InsertOne(ctx, state)
+
+	return err
+}
+
+// UseOIDCState fetches and removes the unexpired state of a login at the
+// provider, so every state can only be used once.
+// Returns the state and an error if any occurred.
+func (m *mongoDBRepo) UseOIDCState(provider, state string) (*models.OIDCState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":        state,
+		"provider":   provider,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	var s OIDCState
+	err := m.DB.Collection("oidc_states").FindOneAndDelete(ctx, filter).Decode(&s)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New(ErrorInvalidToken)
+		}
+		return nil, err
+	}
+
+	return &models.OIDCState{
+		State:     s.State,
+		Provider:  s.Provider,
+		Nonce:     s.Nonce,
+		Verifier:  s.Verifier,
+		ExpiresAt: s.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates all indexes needed by the queries of the repository.
+// Existing indexes are left untouched.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"posts": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publish_at", Value: -1}}},
+			{
+				Keys: bson.D{{Key: "title", Value: "text"}, {Key: "text", Value: "text"}},
+				Options: options.Index().SetName("posts_text").SetWeights(bson.M{
+					"title": utils.SEARCH_TITLE_WEIGHT,
+					"text":  utils.SEARCH_TEXT_WEIGHT,
+				}),
+			},
+			{Keys: bson.D{{Key: "tags", Value: 1}}},
+			{Keys: bson.D{{Key: "category", Value: 1}}},
+			{Keys: bson.D{{Key: "media", Value: 1}}},
+			{Keys: bson.D{{Key: "creator", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{
+				Keys: bson.D{{Key: "slug", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"slug": bson.M{"$exists": true}}),
+			},
+			{
+				Keys: bson.D{{Key: "slug_aliases", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"slug_aliases": bson.M{"$exists": true}}),
+			},
+		},
+		"users": {
+			{Keys: bson.D{{Key: "password_reset.hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{
+				Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"identities": bson.M{"$exists": true}}),
+			},
+		},
+		"comments": {
+			{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "created_at", Value: 1}}},
+			{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+		},
+		"reactions": {
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "user", Value: 1}, {Key: "kind", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		"series": {
+			{Keys: bson.D{{Key: "posts", Value: 1}}},
+		},
+		"sessions": {
+			{Keys: bson.D{{Key: "refresh_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "used_hashes", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"api_keys": {
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"oidc_states": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"audit_log": {
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		},
+		"revisions": {
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "number", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+
+	for collection, models := range indexes {
+		_, err := m.DB.Collection(collection).Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
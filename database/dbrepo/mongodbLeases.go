@@ -0,0 +1,86 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AcquireLease takes or renews the lease with the given name for the holder.
+// A lease can only be taken once it is expired or released by its previous
+// holder.
+// Returns whether the holder got the lease and an error if any occurred.
+func (m *mongoDBRepo) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	collection := m.DB.Collection("leases")
+
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": holder, "expires_at": now.Add(ttl)}}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		// the upsert collides with the lease of another holder
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseLease gives up the lease with the given name if it is held by the
+// holder, so that other holders do not have to wait for it to expire.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) ReleaseLease(name, holder string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := m.DB.Collection("leases")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+
+	return err
+}
+
+// PublishScheduledPosts publishes all scheduled posts whose publish time has
+// passed.
+// Returns the number of published posts and an error if any occurred.
+func (m *mongoDBRepo) PublishScheduledPosts(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := m.DB.Collection("posts")
+
+	filter := bson.M{
+		"status":     models.PostStatusScheduled,
+		"publish_at": bson.M{"$lte": now},
+		"deleted_at": notDeleted(),
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     models.PostStatusPublished,
+		"updated_at": now,
+	}}
+
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,218 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrorMediaInUse = "media is still used by a post or as an avatar"
+
+// Media is the Media type used for communication with the mongo driver.
+type Media struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Owner       string             `bson:"owner,omitempty"`
+	Name        string             `bson:"name,omitempty"`
+	ContentType string             `bson:"content_type,omitempty"`
+	Size        int64              `bson:"size,omitempty"`
+	Width       int                `bson:"width,omitempty"`
+	Height      int                `bson:"height,omitempty"`
+	Key         string             `bson:"key,omitempty"`
+	Thumbnails  []Thumbnail        `bson:"thumbnails,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at,omitempty"`
+}
+
+// Thumbnail is the Thumbnail type used for communication with the mongo driver.
+type Thumbnail struct {
+	Width       int    `bson:"width"`
+	Height      int    `bson:"height"`
+	ContentType string `bson:"content_type"`
+	Key         string `bson:"key"`
+}
+
+// toModelMedia converts a mongoMedia to a models.Media.
+func toModelMedia(media *Media) models.Media {
+	var modelMedia models.Media
+	modelMedia.ID = media.ID.Hex()
+	modelMedia.Owner = media.Owner
+	modelMedia.Name = media.Name
+	modelMedia.ContentType = media.ContentType
+	modelMedia.Size = media.Size
+	modelMedia.Width = media.Width
+	modelMedia.Height = media.Height
+	modelMedia.Key = media.Key
+	modelMedia.Thumbnails = []models.Thumbnail{}
+	for _, thumbnail := range media.Thumbnails {
+		modelMedia.Thumbnails = append(modelMedia.Thumbnails, models.Thumbnail(thumbnail))
+	}
+	modelMedia.CreatedAt = media.CreatedAt
+
+	return modelMedia
+}
+
+// InsertMedia inserts the metadata of an uploaded media into the database.
+// Returns the media ID of the inserted media and an error if any occurred.
+func (m *mongoDBRepo) InsertMedia(md models.Media) (*string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	media := Media{
+		Owner:       md.Owner,
+		Name:        md.Name,
+		ContentType: md.ContentType,
+		Size:        md.Size,
+		Width:       md.Width,
+		Height:      md.Height,
+		Key:         md.Key,
+		CreatedAt:   md.CreatedAt,
+	}
+	for _, thumbnail := range md.Thumbnails {
+		media.Thumbnails = append(media.Thumbnails, Thumbnail(thumbnail))
+	}
+
+	collection := m.DB.Collection("media")
+
+	result, err := collection.InsertOne(ctx, media)
+	if err != nil {
+		return nil, err
+	}
+
+	oid := result.InsertedID.(primitive.ObjectID).Hex()
+
+	return &oid, nil
+}
+
+// GetMediaById gets the metadata of a media by its ID.
+// Returns the media and an error if any occurred.
+func (m *mongoDBRepo) GetMediaById(id string) (*models.Media, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var media Media
+
+	collection := m.DB.Collection("media")
+
+	err = collection.FindOne(ctx, Media{ID: oid}).Decode(&media)
+	if err != nil {
+		return nil, err
+	}
+
+	modelMedia := toModelMedia(&media)
+
+	return &modelMedia, nil
+}
+
+// GetMediaOwner fetches the owner of a media from the database.
+// Returns the owner's id and an error if any occurred.
+func (m *mongoDBRepo) GetMediaOwner(id string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", err
+	}
+
+	collection := m.DB.Collection("media")
+
+	var opts options.FindOneOptions
+	opts.SetProjection(bson.M{"owner": 1})
+
+	var media Media
+	err = collection.FindOne(ctx, Media{ID: oid}, &opts).Decode(&media)
+	if err != nil {
+		return "", err
+	}
+
+	return media.Owner, nil
+}
+
+// MediaExist checks if all media with the given IDs exist and belong to one
+// of the owners. Without owners the media of all users count. An ID may be
+// given more than once, malformed IDs don't exist.
+// Returns the result and an error if any occurred.
+func (m *mongoDBRepo) MediaExist(ids []string, owners []string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	seen := map[primitive.ObjectID]bool{}
+	oids := bson.A{}
+	for _, id := range ids {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return false, nil
+		}
+		if seen[oid] {
+			continue
+		}
+		seen[oid] = true
+		oids = append(oids, oid)
+	}
+
+	collection := m.DB.Collection("media")
+
+	filter := bson.M{"_id": bson.M{"$in": oids}}
+	if len(owners) > 0 {
+		filter["owner"] = bson.M{"$in": owners}
+	}
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return int(count) == len(oids), nil
+}
+
+// DeleteMedia deletes the metadata of a media by its ID. Media which is still
+// referenced by a post or used as an avatar cannot be deleted.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) DeleteMedia(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	count, err := m.DB.Collection("posts").CountDocuments(ctx, bson.M{"media": id})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New(ErrorMediaInUse)
+	}
+
+	count, err = m.DB.Collection("users").CountDocuments(ctx, bson.M{"avatar": id})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New(ErrorMediaInUse)
+	}
+
+	collection := m.DB.Collection("media")
+
+	result, err := collection.DeleteOne(ctx, Media{ID: oid})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
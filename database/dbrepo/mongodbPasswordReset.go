@@ -0,0 +1,69 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PasswordReset is the pending password reset of a user used for
+// communication with the mongo driver. Only the hash of the token is stored.
+type PasswordReset struct {
+	Hash      string    `bson:"hash,omitempty"`
+	ExpiresAt time.Time `bson:"expires_at,omitempty"`
+}
+
+// SetPasswordReset stores the hash of a password reset token for a user. A
+// previous token of the user becomes invalid.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) SetPasswordReset(userID, tokenHash string, expiresAt time.Time) error {
+	reset := PasswordReset{
+		Hash:      tokenHash,
+		ExpiresAt: expiresAt,
+	}
+
+	return m.updateUserFields(userID, bson.M{"$set": bson.M{"password_reset": reset}})
+}
+
+// ResetPassword replaces the password of the user with the unexpired reset
+// token of the given hash. The token is used up and all sessions of the user
+// are revoked.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) ResetPassword(tokenHash, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	collection := m.DB.Collection("users")
+
+	filter := bson.M{
+		"password_reset.hash":       tokenHash,
+		"password_reset.expires_at": bson.M{"$gt": now},
+		"deleted_at":                notDeleted(),
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"password":            password,
+			"sessions_revoked_at": now,
+		},
+		"$unset": bson.M{"password_reset": ""},
+	}
+
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"_id": 1})
+
+	var user User
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New(ErrorInvalidToken)
+		}
+		return err
+	}
+
+	return m.revokeAllSessions(ctx, user.ID.Hex(), now)
+}
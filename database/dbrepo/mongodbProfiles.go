@@ -0,0 +1,57 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetUserProfile retrieves the public profile of a user by its ID. The email
+// and password of the user are never read from the database.
+// Returns the profile and an error if any occurred.
+func (m *mongoDBRepo) GetUserProfile(id string) (*models.PublicProfile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := m.DB.Collection("users")
+
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
+
+	opts := options.FindOne().SetProjection(bson.M{
+		"name":         1,
+		"display_name": 1,
+		"bio":          1,
+		"avatar":       1,
+		"links":        1,
+		"created_at":   1,
+	})
+
+	var user User
+	err = collection.FindOne(ctx, filter, opts).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := models.PublicProfile{
+		ID:   user.ID.Hex(),
+		Name: user.Name,
+		Profile: models.Profile{
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			Avatar:      user.Avatar,
+			Links:       user.Links,
+		},
+		CreatedAt: user.CreatedAt,
+	}
+
+	return &profile, nil
+}
@@ -0,0 +1,97 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reaction is the Reaction type used for communication with the mongo driver.
+// Every user can give each kind of reaction once per post, which is enforced
+// by a unique index.
+type Reaction struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	PostID    string             `bson:"post_id,omitempty"`
+	User      string             `bson:"user,omitempty"`
+	Kind      string             `bson:"kind,omitempty"`
+	CreatedAt time.Time          `bson:"created_at,omitempty"`
+}
+
+// AddReaction adds a reaction of a user to a post and increases the matching
+// counter of the post. Adding the same reaction twice changes nothing.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) AddReaction(postID, userID, kind string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	postOID, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return err
+	}
+
+	reaction := Reaction{
+		PostID:    postID,
+		User:      userID,
+		Kind:      kind,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = m.DB.Collection("reactions").InsertOne(ctx, reaction)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New(ErrorAlreadyUpToDate)
+		}
+		return err
+	}
+
+	// $inc is atomic, so concurrent reactions never lose an increment
+	update := bson.M{"$inc": bson.M{"reactions." + kind: 1}}
+
+	_, err = m.DB.Collection("posts").UpdateByID(ctx, postOID, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveReaction removes a reaction of a user from a post and decreases the
+// matching counter of the post.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) RemoveReaction(postID, userID, kind string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	postOID, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return err
+	}
+
+	filter := Reaction{
+		PostID: postID,
+		User:   userID,
+		Kind:   kind,
+	}
+
+	result, err := m.DB.Collection("reactions").DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New(ErrorAlreadyUpToDate)
+	}
+
+	update := bson.M{"$inc": bson.M{"reactions." + kind: -1}}
+
+	_, err = m.DB.Collection("posts").UpdateByID(ctx, postOID, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Revision is the Revision type used for communication with the mongo driver.
+type Revision struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	PostID    string             `bson:"post_id"`
+	Number    int                `bson:"number"`
+	Title     string             `bson:"title"`
+	Text      string             `bson:"text"`
+	Editor    string             `bson:"editor"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// toModelRevision converts a mongoRevision to a models.Revision.
+func toModelRevision(revision *Revision) models.Revision {
+	var modelRevision models.Revision
+	modelRevision.ID = revision.ID.Hex()
+	modelRevision.PostID = revision.PostID
+	modelRevision.Number = revision.Number
+	modelRevision.Title = revision.Title
+	modelRevision.Text = revision.Text
+	modelRevision.Editor = revision.Editor
+	modelRevision.CreatedAt = revision.CreatedAt
+
+	return modelRevision
+}
+
+// firstNonEmpty returns the first of the given strings which is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// GetRevisions gets all revisions of a post, the newest first.
+// Returns a list of revisions and an error if any occurred.
+func (m *mongoDBRepo) GetRevisions(postID string) ([]*models.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revisions := []*models.Revision{}
+
+	collection := m.DB.Collection("revisions")
+
+	filter := bson.M{"post_id": postID}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "number", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var revision Revision
+		cursor.Decode(&revision)
+
+		newRevision := toModelRevision(&revision)
+
+		revisions = append(revisions, &newRevision)
+	}
+
+	return revisions, nil
+}
+
+// GetRevision gets a single revision of a post by its number.
+// Returns the revision and an error if any occurred.
+func (m *mongoDBRepo) GetRevision(postID string, number int) (*models.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var revision Revision
+
+	collection := m.DB.Collection("revisions")
+
+	filter := bson.M{"post_id": postID, "number": number}
+
+	err := collection.FindOne(ctx, filter).Decode(&revision)
+	if err != nil {
+		return nil, err
+	}
+
+	modelRevision := toModelRevision(&revision)
+
+	return &modelRevision, nil
+}
@@ -0,0 +1,243 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrorSeriesEmpty = "title, description and posts cannot be empty"
+
+// Series is the Series type used for communication with the mongo driver.
+type Series struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Creator     string             `bson:"creator,omitempty"`
+	Posts       []string           `bson:"posts,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at,omitempty"`
+	UpdatedAt   time.Time          `bson:"updated_at,omitempty"`
+}
+
+// toModelSeries converts a mongoSeries to a models.Series.
+func toModelSeries(series *Series) models.Series {
+	var modelSeries models.Series
+	modelSeries.ID = series.ID.Hex()
+	modelSeries.Title = series.Title
+	modelSeries.Description = series.Description
+	modelSeries.Creator = series.Creator
+	modelSeries.Posts = series.Posts
+	if modelSeries.Posts == nil {
+		modelSeries.Posts = []string{}
+	}
+	modelSeries.CreatedAt = series.CreatedAt
+	modelSeries.UpdatedAt = series.UpdatedAt
+
+	return modelSeries
+}
+
+// InsertSeries inserts a given series into the database.
+// Returns the series ID of the inserted series and an error if any occurred.
+func (m *mongoDBRepo) InsertSeries(s models.Series) (*string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	series := Series{
+		Title:       s.Title,
+		Description: s.Description,
+		Creator:     s.Creator,
+		Posts:       s.Posts,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+
+	collection := m.DB.Collection("series")
+
+	result, err := collection.InsertOne(ctx, series)
+	if err != nil {
+		return nil, err
+	}
+
+	oid := result.InsertedID.(primitive.ObjectID).Hex()
+
+	return &oid, nil
+}
+
+// GetAllSeries gets a list of all series, the newest first.
+// Returns a list of series and an error if any occurred.
+func (m *mongoDBRepo) GetAllSeries() ([]*models.Series, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allSeries := []*models.Series{}
+
+	collection := m.DB.Collection("series")
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var series Series
+		cursor.Decode(&series)
+
+		newSeries := toModelSeries(&series)
+
+		allSeries = append(allSeries, &newSeries)
+	}
+
+	return allSeries, nil
+}
+
+// GetSeriesById gets a series from the database by its ID.
+// Returns a series and an error if any occurred.
+func (m *mongoDBRepo) GetSeriesById(id string) (*models.Series, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var series Series
+
+	collection := m.DB.Collection("series")
+
+	err = collection.FindOne(ctx, Series{ID: oid}).Decode(&series)
+	if err != nil {
+		return nil, err
+	}
+
+	modelSeries := toModelSeries(&series)
+
+	return &modelSeries, nil
+}
+
+// GetSeriesByPost gets the oldest series which contains the given post.
+// Returns a series and an error if any occurred.
+func (m *mongoDBRepo) GetSeriesByPost(postID string) (*models.Series, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var series Series
+
+	collection := m.DB.Collection("series")
+
+	opts := options.FindOne()
+	opts.SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	err := collection.FindOne(ctx, bson.M{"posts": postID}, opts).Decode(&series)
+	if err != nil {
+		return nil, err
+	}
+
+	modelSeries := toModelSeries(&series)
+
+	return &modelSeries, nil
+}
+
+// GetSeriesCreator fetches the creator of a series from the database.
+// Returns the creator's id and an error if any occurred.
+func (m *mongoDBRepo) GetSeriesCreator(id string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", err
+	}
+
+	collection := m.DB.Collection("series")
+
+	var opts options.FindOneOptions
+	opts.SetProjection(bson.M{"creator": 1})
+
+	var series Series
+	err = collection.FindOne(ctx, Series{ID: oid}, &opts).Decode(&series)
+	if err != nil {
+		return "", err
+	}
+
+	return series.Creator, nil
+}
+
+// UpdateSeries updates a given series in the database. An empty list of
+// posts removes all posts from the series.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) UpdateSeries(s models.Series) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if s.Title == "" && s.Description == "" && s.Posts == nil {
+		return errors.New(ErrorSeriesEmpty)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(s.ID)
+	if err != nil {
+		return err
+	}
+
+	series := Series{
+		Title:       s.Title,
+		Description: s.Description,
+		Posts:       s.Posts,
+		UpdatedAt:   time.Now(),
+	}
+
+	update := bson.M{"$set": series}
+	if s.Posts != nil && len(s.Posts) == 0 {
+		update["$unset"] = bson.M{"posts": ""}
+	}
+
+	collection := m.DB.Collection("series")
+
+	result, err := collection.UpdateByID(ctx, oid, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSeries deletes a series from the database by its ID. The posts of the
+// series are kept.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) DeleteSeries(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("series")
+
+	result, err := collection.DeleteOne(ctx, Series{ID: oid})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
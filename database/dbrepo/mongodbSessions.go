@@ -0,0 +1,226 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrorRefreshTokenReused = "refresh token was already used"
+
+// Session is the Session type used for communication with the mongo driver.
+// Only the hashes of the refresh tokens are stored.
+type Session struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      string             `bson:"user_id,omitempty"`
+	RefreshHash string             `bson:"refresh_hash,omitempty"`
+	UsedHashes  []string           `bson:"used_hashes,omitempty"`
+	UserAgent   string             `bson:"user_agent,omitempty"`
+	IP          string             `bson:"ip,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at,omitempty"`
+	LastUsedAt  time.Time          `bson:"last_used_at,omitempty"`
+	ExpiresAt   time.Time          `bson:"expires_at,omitempty"`
+	RevokedAt   time.Time          `bson:"revoked_at,omitempty"`
+}
+
+// toModelSession converts a mongoSession to a models.Session.
+func toModelSession(session *Session) models.Session {
+	var modelSession models.Session
+	modelSession.ID = session.ID.Hex()
+	modelSession.UserID = session.UserID
+	modelSession.UserAgent = session.UserAgent
+	modelSession.IP = session.IP
+	modelSession.CreatedAt = session.CreatedAt
+	modelSession.LastUsedAt = session.LastUsedAt
+	modelSession.ExpiresAt = session.ExpiresAt
+	return modelSession
+}
+
+// activeSession returns the filter for sessions which are neither revoked nor
+// expired.
+func activeSession(now time.Time) bson.M {
+	return bson.M{
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+}
+
+// InsertSession starts a new session with the hash of its first refresh
+// token.
+// Returns the ID of the session and an error if any occurred.
+func (m *mongoDBRepo) InsertSession(s models.Session, refreshHash string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session := Session{
+		UserID:      s.UserID,
+		RefreshHash: refreshHash,
+		UserAgent:   s.UserAgent,
+		IP:          s.IP,
+		CreatedAt:   s.CreatedAt,
+		LastUsedAt:  s.CreatedAt,
+		ExpiresAt:   s.ExpiresAt,
+	}
+
+	result, err := m.DB.Collection("sessions").InsertOne(ctx, session)
+	if err != nil {
+		return "", err
+	}
+
+	return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// RotateSession replaces the refresh token of the active session with the
+// given hash by a new one. Presenting a refresh token which was already
+// rotated revokes its session, since the token must have been stolen.
+// Returns the session and an error if any occurred.
+func (m *mongoDBRepo) RotateSession(refreshHash, newRefreshHash, userAgent, ip string) (*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	collection := m.DB.Collection("sessions")
+
+	filter := activeSession(now)
+	filter["refresh_hash"] = refreshHash
+	update := bson.M{
+		"$set": bson.M{
+			"refresh_hash": newRefreshHash,
+			"user_agent":   userAgent,
+			"ip":           ip,
+			"last_used_at": now,
+		},
+		"$push": bson.M{"used_hashes": refreshHash},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var session Session
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&session)
+	if err == nil {
+		rotatedSession := toModelSession(&session)
+		return &rotatedSession, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// reuse detection
+	filter = bson.M{"used_hashes": refreshHash, "revoked_at": bson.M{"$exists": false}}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": now}})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.MatchedCount > 0 {
+		return nil, errors.New(ErrorRefreshTokenReused)
+	}
+
+	return nil, errors.New(ErrorInvalidToken)
+}
+
+// GetActiveSession fetches a session which is neither revoked nor expired.
+// Returns the session and an error if any occurred.
+func (m *mongoDBRepo) GetActiveSession(id string) (*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := activeSession(time.Now())
+	filter["_id"] = oid
+
+	var session Session
+	err = m.DB.Collection("sessions").FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedSession := toModelSession(&session)
+
+	return &fetchedSession, nil
+}
+
+// GetActiveSessions fetches all sessions of a user which are neither revoked
+// nor expired, the newest first.
+// Returns a list of sessions and an error if any occurred.
+func (m *mongoDBRepo) GetActiveSessions(userID string) ([]*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessions := []*models.Session{}
+
+	collection := m.DB.Collection("sessions")
+
+	filter := activeSession(time.Now())
+	filter["user_id"] = userID
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var session Session
+		cursor.Decode(&session)
+
+		newSession := toModelSession(&session)
+
+		sessions = append(sessions, &newSession)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends an active session of the user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) RevokeSession(userID, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	filter := activeSession(now)
+	filter["_id"] = oid
+	filter["user_id"] = userID
+
+	result, err := m.DB.Collection("sessions").UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": now}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New(ErrorDocumentNotFound)
+	}
+
+	return nil
+}
+
+// revokeAllSessions ends all active sessions of the user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) revokeAllSessions(ctx context.Context, userID string, at time.Time) error {
+	filter := activeSession(at)
+	filter["user_id"] = userID
+
+	_, err := m.DB.Collection("sessions").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+
+	return err
+}
@@ -0,0 +1,70 @@
+package dbrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// slugFilter returns the filter matching the post which uses the given slug
+// either as its current slug or as an alias.
+func slugFilter(slug string) bson.M {
+	return bson.M{
+		"$or": bson.A{
+			bson.M{"slug": slug},
+			bson.M{"slug_aliases": slug},
+		},
+	}
+}
+
+// uniqueSlug creates a slug from the title which is not used by any other
+// post. Slugs already belonging to the post with the given ID are reused.
+func (m *mongoDBRepo) uniqueSlug(ctx context.Context, title string, postID primitive.ObjectID) (string, error) {
+	collection := m.DB.Collection("posts")
+
+	return utils.UniqueSlug(title, func(slug string) (bool, error) {
+		filter := slugFilter(slug)
+		filter["_id"] = bson.M{"$ne": postID}
+
+		count, err := collection.CountDocuments(ctx, filter)
+		return count > 0, err
+	})
+}
+
+// withoutString returns a copy of the slice without the given value.
+func withoutString(values []string, value string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// GetPostBySlug gets a post by its current slug or one of its old aliases.
+// Returns the post and an error if any occurred.
+func (m *mongoDBRepo) GetPostBySlug(slug string) (*models.Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var post Post
+
+	collection := m.DB.Collection("posts")
+
+	filter := slugFilter(slug)
+	filter["deleted_at"] = notDeleted()
+
+	err := collection.FindOne(ctx, filter).Decode(&post)
+	if err != nil {
+		return nil, err
+	}
+
+	modelPost := toModelPost(&post)
+
+	return &modelPost, nil
+}
@@ -0,0 +1,221 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// deletedFilter returns the filter matching all documents in the trash.
+func deletedFilter() bson.M {
+	return bson.M{"deleted_at": bson.M{"$exists": true}}
+}
+
+// GetDeletedPosts gets all posts in the trash, the most recently deleted
+// first. If a creator is given only the posts of this creator are returned.
+// Returns a list of posts and an error if any occurred.
+func (m *mongoDBRepo) GetDeletedPosts(creator string) ([]*models.Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	posts := []*models.Post{}
+
+	collection := m.DB.Collection("posts")
+
+	filter := deletedFilter()
+	if creator != "" {
+		filter["creator"] = creator
+	}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post Post
+		cursor.Decode(&post)
+
+		newPost := toModelPost(&post)
+
+		posts = append(posts, &newPost)
+	}
+
+	return posts, nil
+}
+
+// RestorePost moves a post out of the trash by its ID.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) RestorePost(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("posts")
+
+	filter := deletedFilter()
+	filter["_id"] = oid
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
+
+// GetDeletedUsers gets all users in the trash, the most recently deleted
+// first.
+// Returns a list of users and an error if any occurred.
+func (m *mongoDBRepo) GetDeletedUsers() ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	users := []*models.User{}
+
+	collection := m.DB.Collection("users")
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	opts.SetProjection(bson.M{"password": 0})
+
+	cursor, err := collection.Find(ctx, deletedFilter(), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user User
+		cursor.Decode(&user)
+
+		newUser := toModelUser(&user)
+
+		users = append(users, &newUser)
+	}
+
+	return users, nil
+}
+
+// RestoreUser moves a user out of the trash by its ID.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) RestoreUser(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	collection := m.DB.Collection("users")
+
+	filter := deletedFilter()
+	filter["_id"] = oid
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		err = errors.New(ErrorDocumentNotFound)
+		return err
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently deletes all posts and users which were moved to
+// the trash before the given time. The revisions, comments and reactions of
+// the purged posts are deleted with them.
+// Returns the number of purged documents and an error if any occurred.
+func (m *mongoDBRepo) PurgeDeleted(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deleted_at": bson.M{"$lt": before}}
+
+	cursor, err := m.DB.Collection("posts").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var purged int64
+	for cursor.Next(ctx) {
+		var post Post
+		err = cursor.Decode(&post)
+		if err != nil {
+			return purged, err
+		}
+
+		err = m.purgePost(ctx, post.ID)
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	result, err := m.DB.Collection("users").DeleteMany(ctx, filter)
+	if err != nil {
+		return purged, err
+	}
+	purged += result.DeletedCount
+
+	return purged, nil
+}
+
+// purgePost permanently deletes a post together with everything attached to
+// it.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) purgePost(ctx context.Context, oid primitive.ObjectID) error {
+	id := oid.Hex()
+
+	_, err := m.DB.Collection("revisions").DeleteMany(ctx, bson.M{"post_id": id})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.Collection("comments").DeleteMany(ctx, Comment{PostID: id})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.Collection("reactions").DeleteMany(ctx, Reaction{PostID: id})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.Collection("series").UpdateMany(ctx, bson.M{"posts": id}, bson.M{"$pull": bson.M{"posts": id}})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.Collection("posts").DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrorTwoFactorEnabled = "two-factor authentication is already enabled"
+var ErrorNoTwoFactorEnrolment = "no two-factor enrolment is pending"
+
+// TwoFactor is the TOTP two-factor authentication of a user used for
+// communication with the mongo driver. Only the hashes of the recovery codes
+// are stored.
+type TwoFactor struct {
+	Secret         string              `bson:"secret,omitempty"`
+	PendingSecret  string              `bson:"pending_secret,omitempty"`
+	EnabledAt      time.Time           `bson:"enabled_at,omitempty"`
+	RecoveryHashes []string            `bson:"recovery_hashes,omitempty"`
+	LastStep       int64               `bson:"last_step,omitempty"`
+	Challenge      *TwoFactorChallenge `bson:"challenge,omitempty"`
+}
+
+// TwoFactorChallenge is the pending second login step of a user.
+type TwoFactorChallenge struct {
+	ID       string `bson:"id"`
+	Attempts int    `bson:"attempts"`
+}
+
+// toModelTwoFactor converts a mongoTwoFactor to a models.TwoFactor.
+func toModelTwoFactor(twoFactor *TwoFactor) *models.TwoFactor {
+	if twoFactor == nil {
+		return nil
+	}
+
+	var modelTwoFactor models.TwoFactor
+	modelTwoFactor.Secret = twoFactor.Secret
+	modelTwoFactor.PendingSecret = twoFactor.PendingSecret
+	if !twoFactor.EnabledAt.IsZero() {
+		modelTwoFactor.EnabledAt = &twoFactor.EnabledAt
+	}
+	modelTwoFactor.RecoveryCodes = len(twoFactor.RecoveryHashes)
+	return &modelTwoFactor
+}
+
+// updateTwoFactor applies the update to a user which is not in the trash and
+// matches the filter.
+// Returns an error, which is the given one if no user matched.
+func (m *mongoDBRepo) updateTwoFactor(userID string, filter, update bson.M, noMatch string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	filter["_id"] = oid
+	filter["deleted_at"] = notDeleted()
+
+	result, err := m.DB.Collection("users").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New(noMatch)
+	}
+
+	return nil
+}
+
+// SetTwoFactorPending starts the enrolment of a user with a new secret, which
+// replaces the secret of an unfinished enrolment.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) SetTwoFactorPending(userID, secret string) error {
+	filter := bson.M{"two_factor.enabled_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"two_factor": TwoFactor{PendingSecret: secret}}}
+
+	return m.updateTwoFactor(userID, filter, update, ErrorTwoFactorEnabled)
+}
+
+// EnableTwoFactor finishes the enrolment of the pending secret. The time step
+// of the confirming code can't be used again.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) EnableTwoFactor(userID, secret string, step int64, recoveryHashes []string) error {
+	filter := bson.M{"two_factor.pending_secret": secret}
+	update := bson.M{"$set": bson.M{"two_factor": TwoFactor{
+		Secret:         secret,
+		EnabledAt:      time.Now(),
+		RecoveryHashes: recoveryHashes,
+		LastStep:       step,
+	}}}
+
+	return m.updateTwoFactor(userID, filter, update, ErrorNoTwoFactorEnrolment)
+}
+
+// DisableTwoFactor removes the two-factor authentication of a user.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) DisableTwoFactor(userID string) error {
+	return m.updateUserFields(userID, bson.M{"$unset": bson.M{"two_factor": ""}})
+}
+
+// UseTOTPStep records the time step of a valid code. Every step can only be
+// used once, so an observed code can't be replayed.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) UseTOTPStep(userID string, step int64) error {
+	filter := bson.M{
+		"two_factor.enabled_at": bson.M{"$exists": true},
+		"two_factor.last_step":  bson.M{"$lt": step},
+	}
+	update := bson.M{"$set": bson.M{"two_factor.last_step": step}}
+
+	return m.updateTwoFactor(userID, filter, update, ErrorInvalidToken)
+}
+
+// UseRecoveryCode uses up the recovery code with the given hash.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) UseRecoveryCode(userID, hash string) error {
+	filter := bson.M{
+		"two_factor.enabled_at":      bson.M{"$exists": true},
+		"two_factor.recovery_hashes": hash,
+	}
+	update := bson.M{"$pull": bson.M{"two_factor.recovery_hashes": hash}}
+
+	return m.updateTwoFactor(userID, filter, update, ErrorInvalidToken)
+}
+
+// StartTwoFactorChallenge starts the second login step of a user, which
+// replaces a previous one.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) StartTwoFactorChallenge(userID, challengeID string) error {
+	filter := bson.M{"two_factor.enabled_at": bson.M{"$exists": true}}
+	update := bson.M{"$set": bson.M{"two_factor.challenge": TwoFactorChallenge{ID: challengeID}}}
+
+	return m.updateTwoFactor(userID, filter, update, ErrorInvalidToken)
+}
+
+// AttemptTwoFactorChallenge counts an attempt to pass the second login step.
+// Returns an error if the challenge doesn't exist or ran out of attempts.
+func (m *mongoDBRepo) AttemptTwoFactorChallenge(userID, challengeID string, maxAttempts int) error {
+	filter := bson.M{
+		"two_factor.challenge.id":       challengeID,
+		"two_factor.challenge.attempts": bson.M{"$lt": maxAttempts},
+	}
+	update := bson.M{"$inc": bson.M{"two_factor.challenge.attempts": 1}}
+
+	return m.updateTwoFactor(userID, filter, update, ErrorInvalidToken)
+}
+
+// EndTwoFactorChallenge removes the second login step of a user, so its
+// challenge can't be used again.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) EndTwoFactorChallenge(userID string) error {
+	return m.updateUserFields(userID, bson.M{"$unset": bson.M{"two_factor.challenge": ""}})
+}
+
+// GetTwoFactorRoles fetches the roles whose users need two-factor
+// authentication to use them.
+// Returns the roles and an error if any occurred.
+func (m *mongoDBRepo) GetTwoFactorRoles() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.getTwoFactorRoles(ctx)
+}
+
+// getTwoFactorRoles reads the roles needing two-factor authentication from
+// the settings.
+// Returns the roles and an error if any occurred.
+func (m *mongoDBRepo) getTwoFactorRoles(ctx context.Context) ([]string, error) {
+	var settings struct {
+		Roles []string `bson:"roles"`
+	}
+
+	err := m.DB.Collection("settings").FindOne(ctx, bson.M{"_id": "two_factor"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.Roles == nil {
+		settings.Roles = []string{}
+	}
+
+	return settings.Roles, nil
+}
+
+// SetTwoFactorRoles replaces the roles whose users need two-factor
+// authentication to use them.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) SetTwoFactorRoles(roles []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": "two_factor"}
+	update := bson.M{"$set": bson.M{"roles": roles}}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := m.DB.Collection("settings").UpdateOne(ctx, filter, update, opts)
+
+	return err
+}
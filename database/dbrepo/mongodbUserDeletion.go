@@ -0,0 +1,131 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrorUserHasPosts = "user still has posts"
+var ErrorUnknownDeletionPolicy = "unknown user deletion policy"
+var ErrorGhostUserDeletion = "the ghost user cannot be deleted"
+
+// GhostUserID is the ID of the user which takes over the posts of deleted
+// users with the ghost policy.
+const GhostUserID = "000000000000000000000001"
+
+// GhostUserName is the name of the ghost user.
+const GhostUserName = "ghost"
+
+// DeleteUser moves a user to the trash by setting its deletion time. The
+// policy decides what happens to the posts of the user:
+// models.UserDeletionRefuse fails while the user has posts,
+// models.UserDeletionGhost hands the posts over to the ghost user and
+// models.UserDeletionDelete moves the posts to the trash as well.
+// Everything runs in one transaction.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) DeleteUser(id, policy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	if id == GhostUserID {
+		return errors.New(ErrorGhostUserDeletion)
+	}
+
+	session, err := m.DB.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, m.deleteUser(sc, oid, policy)
+	})
+
+	return err
+}
+
+// deleteUser applies the deletion policy to the posts of a user and moves the
+// user to the trash within the transaction of the session context.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) deleteUser(sc mongo.SessionContext, oid primitive.ObjectID, policy string) error {
+	now := time.Now()
+	id := oid.Hex()
+	posts := m.DB.Collection("posts")
+
+	switch policy {
+	case models.UserDeletionRefuse:
+		count, err := posts.CountDocuments(sc, bson.M{"creator": id})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New(ErrorUserHasPosts)
+		}
+	case models.UserDeletionGhost:
+		err := m.ensureGhostUser(sc)
+		if err != nil {
+			return err
+		}
+
+		update := bson.M{"$set": bson.M{"creator": GhostUserID}}
+		_, err = posts.UpdateMany(sc, bson.M{"creator": id}, update)
+		if err != nil {
+			return err
+		}
+	case models.UserDeletionDelete:
+		filter := bson.M{"creator": id, "deleted_at": notDeleted()}
+		update := bson.M{"$set": bson.M{"deleted_at": now}}
+		_, err := posts.UpdateMany(sc, filter, update)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New(ErrorUnknownDeletionPolicy)
+	}
+
+	filter := bson.M{"_id": oid, "deleted_at": notDeleted()}
+	update := bson.M{"$set": bson.M{"deleted_at": now}}
+
+	result, err := m.DB.Collection("users").UpdateOne(sc, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New(ErrorDocumentNotFound)
+	}
+
+	return nil
+}
+
+// ensureGhostUser creates the ghost user if it does not exist yet. The ghost
+// user has neither an email nor a password, so nobody can log in as it.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) ensureGhostUser(ctx context.Context) error {
+	oid, err := primitive.ObjectIDFromHex(GhostUserID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$setOnInsert": bson.M{
+		"name":       GhostUserName,
+		"roles":      bson.A{GhostUserName},
+		"created_at": time.Now(),
+	}}
+	opts := options.Update().SetUpsert(true)
+
+	_, err = m.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": oid}, update, opts)
+
+	return err
+}
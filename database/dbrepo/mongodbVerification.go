@@ -0,0 +1,51 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrorInvalidToken = "invalid or expired token"
+
+// SetEmailVerification marks the email of a user as unverified until the
+// token with the given ID is confirmed. A previous token becomes invalid.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) SetEmailVerification(userID, tokenID string) error {
+	return m.updateUserFields(userID, bson.M{"$set": bson.M{"email_verification_id": tokenID}})
+}
+
+// VerifyEmail confirms the email of a user with the ID of a verification
+// token. Each token can only be used once.
+// Returns an error if any occurred.
+func (m *mongoDBRepo) VerifyEmail(userID, tokenID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New(ErrorInvalidToken)
+	}
+
+	collection := m.DB.Collection("users")
+
+	filter := bson.M{"_id": oid, "email_verification_id": tokenID, "deleted_at": notDeleted()}
+	update := bson.M{
+		"$set":   bson.M{"email_verified_at": time.Now()},
+		"$unset": bson.M{"email_verification_id": ""},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New(ErrorInvalidToken)
+	}
+
+	return nil
+}
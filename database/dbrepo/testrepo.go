@@ -1,20 +1,153 @@
 package dbrepo
 
 import (
+	"sort"
+	"time"
+
 	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/schattenbrot/mini-blog-api/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// testPosts are the posts of the testing repository. Only the published ones
+// are listed and searched, like in the mongo repository. The first post was
+// published long after it was written.
+var testPosts = []models.Post{
+	{
+		ID:        "000000000000000000000001",
+		Title:     "Getting started with Go",
+		Slug:      "getting-started-with-go",
+		Text:      "Install the toolchain, write a main package and run it.",
+		Creator:   "000000000000000000000101",
+		Tags:      []string{"go", "beginners"},
+		Category:  "programming",
+		Status:    models.PostStatusPublished,
+		PublishAt: time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:        "000000000000000000000002",
+		Title:     "Testing in Go",
+		Slug:      "testing-in-go",
+		Text:      "Table tests keep the cases of a function together. Go runs them with go test.",
+		Creator:   "000000000000000000000101",
+		Tags:      []string{"go", "testing"},
+		Category:  "programming",
+		Status:    models.PostStatusPublished,
+		PublishAt: time.Date(2022, 2, 14, 12, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2022, 2, 14, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:        "000000000000000000000003",
+		Title:     "Sourdough bread",
+		Slug:      "sourdough-bread",
+		Text:      "Feed the starter the evening before. Unlike Go, bread needs patience.",
+		Creator:   "000000000000000000000102",
+		Tags:      []string{"baking"},
+		Category:  "food",
+		Status:    models.PostStatusPublished,
+		PublishAt: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:        "000000000000000000000004",
+		Title:     "Mongo text indexes",
+		Slug:      "mongo-text-indexes",
+		Text:      "A text index weights fields, so a title match ranks above a match in the text.",
+		Creator:   "000000000000000000000102",
+		Tags:      []string{"mongodb"},
+		Category:  "programming",
+		CreatedAt: time.Date(2021, 12, 24, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:        "000000000000000000000005",
+		Title:     "Go generics draft",
+		Slug:      "go-generics-draft",
+		Text:      "Type parameters are coming to Go.",
+		Creator:   "000000000000000000000101",
+		Tags:      []string{"go"},
+		Category:  "programming",
+		Status:    models.PostStatusDraft,
+		CreatedAt: time.Date(2022, 3, 5, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:        "000000000000000000000006",
+		Title:     "Go modules in depth",
+		Slug:      "go-modules-in-depth",
+		Text:      "Scheduled for the future.",
+		Creator:   "000000000000000000000101",
+		Tags:      []string{"go"},
+		Category:  "programming",
+		Status:    models.PostStatusPublished,
+		PublishAt: time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2022, 3, 6, 12, 0, 0, 0, time.UTC),
+	},
+}
+
+// paginate returns the bounds of the page in a list of the given length.
+func paginate(length, page, limit int) (int, int) {
+	from := (page - 1) * limit
+	if from < 0 || from >= length {
+		return 0, 0
+	}
+	to := from + limit
+	if to > length {
+		to = length
+	}
+	return from, to
+}
+
+// containsTag checks if the tags contain the tag.
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *testDBRepo) EnsureIndexes() error {
+	return nil
+}
+
 func (m *testDBRepo) InsertPost(p models.Post) (*string, error) {
 	return nil, nil
 }
 
-func (m *testDBRepo) GetPosts() ([]*models.Post, error) {
-	var posts []*models.Post
+func (m *testDBRepo) GetPosts(filter models.PostFilter) ([]*models.Post, error) {
+	posts := []*models.Post{}
+
+	now := time.Now()
+	for i := range testPosts {
+		post := testPosts[i]
+		if !post.IsPublic(now) || post.DeletedAt != nil {
+			continue
+		}
+		if filter.Tag != "" && !containsTag(post.Tags, filter.Tag) {
+			continue
+		}
+		if filter.Category != "" && post.Category != filter.Category {
+			continue
+		}
+		if filter.Creator != "" && post.Creator != filter.Creator {
+			continue
+		}
+		posts = append(posts, &post)
+	}
+
+	// the latest published first, posts without publish time last
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].PublishAt.Equal(posts[j].PublishAt) {
+			return posts[i].PublishAt.After(posts[j].PublishAt)
+		}
+		return posts[i].CreatedAt.After(posts[j].CreatedAt)
+	})
 
 	return posts, nil
 }
 
-func (m *testDBRepo) UpdatePost(p models.Post) error {
+func (m *testDBRepo) UpdatePost(p models.Post, editor string) error {
 	return nil
 }
 
@@ -22,14 +155,141 @@ func (m *testDBRepo) DeleteOnePost(id string) error {
 	return nil
 }
 
+func (m *testDBRepo) GetDeletedPosts(creator string) ([]*models.Post, error) {
+	return []*models.Post{}, nil
+}
+
+func (m *testDBRepo) RestorePost(id string) error {
+	return nil
+}
+
 func (m *testDBRepo) GetPostById(id string) (*models.Post, error) {
 	return nil, nil
 }
 
-func (m *testDBRepo) GetPostsByPage(page, limit int) ([]*models.Post, error) {
+func (m *testDBRepo) GetPostBySlug(slug string) (*models.Post, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetPostsByPage(page, limit int, filter models.PostFilter) ([]*models.Post, error) {
+	posts, err := m.GetPosts(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := paginate(len(posts), page, limit)
+
+	return posts[from:to], nil
+}
+
+func (m *testDBRepo) SearchPosts(query string, page, limit int) ([]*models.SearchResult, error) {
+	posts, err := m.GetPosts(models.PostFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	results := utils.RankPosts(posts, query)
+
+	from, to := paginate(len(results), page, limit)
+
+	return results[from:to], nil
+}
+
+func (m *testDBRepo) GetTagCounts() ([]*models.TagCount, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetDraftsByCreator(creator string) ([]*models.Post, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetRevisions(postID string) ([]*models.Revision, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetRevision(postID string, number int) (*models.Revision, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) InsertComment(c models.Comment) (*string, error) {
+	var s string
+	return &s, nil
+}
+
+func (m *testDBRepo) GetCommentsByPost(postID string) ([]*models.Comment, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetCommentAuthor(id string) (string, error) {
+	return "", nil
+}
+
+func (m *testDBRepo) UpdateComment(c models.Comment) error {
+	return nil
+}
+
+func (m *testDBRepo) DeleteComment(postID, id string) error {
+	return nil
+}
+
+func (m *testDBRepo) AddReaction(postID, userID, kind string) error {
+	return nil
+}
+
+func (m *testDBRepo) RemoveReaction(postID, userID, kind string) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertSeries(s models.Series) (*string, error) {
+	var id string
+	return &id, nil
+}
+
+func (m *testDBRepo) GetAllSeries() ([]*models.Series, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetSeriesById(id string) (*models.Series, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetSeriesByPost(postID string) (*models.Series, error) {
 	return nil, nil
 }
 
+func (m *testDBRepo) GetSeriesCreator(id string) (string, error) {
+	return "", nil
+}
+
+func (m *testDBRepo) UpdateSeries(s models.Series) error {
+	return nil
+}
+
+func (m *testDBRepo) DeleteSeries(id string) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertMedia(md models.Media) (*string, error) {
+	var s string
+	return &s, nil
+}
+
+func (m *testDBRepo) GetMediaById(id string) (*models.Media, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) GetMediaOwner(id string) (string, error) {
+	return "", nil
+}
+
+func (m *testDBRepo) MediaExist(ids []string, owners []string) (bool, error) {
+	return true, nil
+}
+
+func (m *testDBRepo) DeleteMedia(id string) error {
+	return nil
+}
+
 func (m *testDBRepo) InsertUser(u models.User) (*string, error) {
 	var s string
 	return &s, nil
@@ -40,16 +300,181 @@ func (m *testDBRepo) GetUserById(id string) (*models.User, error) {
 	return &user, nil
 }
 
+func (m *testDBRepo) GetUserProfile(id string) (*models.PublicProfile, error) {
+	var profile models.PublicProfile
+	return &profile, nil
+}
+
 func (m *testDBRepo) UpdateUser(u models.User) error {
 	return nil
 }
 
-func (m *testDBRepo) DeleteUser(id string) error {
+func (m *testDBRepo) DeleteUser(id, policy string) error {
+	return nil
+}
+
+func (m *testDBRepo) GetDeletedUsers() ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
+func (m *testDBRepo) RestoreUser(id string) error {
+	return nil
+}
+
+func (m *testDBRepo) PurgeDeleted(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) SetEmailVerification(userID, tokenID string) error {
+	return nil
+}
+
+func (m *testDBRepo) VerifyEmail(userID, tokenID string) error {
+	return nil
+}
+
+func (m *testDBRepo) SetPasswordReset(userID, tokenHash string, expiresAt time.Time) error {
+	return nil
+}
+
+func (m *testDBRepo) ResetPassword(tokenHash, password string) error {
+	return nil
+}
+
+func (m *testDBRepo) GetUsers(query string, page, limit int) ([]*models.User, error) {
+	return []*models.User{}, nil
+}
+
+func (m *testDBRepo) SetUserRoles(id string, roles []string) error {
+	return nil
+}
+
+func (m *testDBRepo) SuspendUser(id string, s models.Suspension) error {
+	return nil
+}
+
+func (m *testDBRepo) UnsuspendUser(id string) error {
+	return nil
+}
+
+func (m *testDBRepo) RevokeSessions(id string) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertSession(s models.Session, refreshHash string) (string, error) {
+	return "", nil
+}
+
+func (m *testDBRepo) RotateSession(refreshHash, newRefreshHash, userAgent, ip string) (*models.Session, error) {
+	return &models.Session{}, nil
+}
+
+func (m *testDBRepo) GetActiveSession(id string) (*models.Session, error) {
+	return &models.Session{}, nil
+}
+
+func (m *testDBRepo) GetActiveSessions(userID string) ([]*models.Session, error) {
+	return []*models.Session{}, nil
+}
+
+func (m *testDBRepo) RevokeSession(userID, id string) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertAPIKey(k models.APIKey, hash string) (string, error) {
+	return "", nil
+}
+
+func (m *testDBRepo) UseAPIKey(hash string) (*models.APIKey, error) {
+	return &models.APIKey{}, nil
+}
+
+func (m *testDBRepo) GetAPIKeys(userID string) ([]*models.APIKey, error) {
+	return []*models.APIKey{}, nil
+}
+
+func (m *testDBRepo) DeleteAPIKey(userID, id string) error {
+	return nil
+}
+
+func (m *testDBRepo) SetTwoFactorPending(userID, secret string) error {
+	return nil
+}
+
+func (m *testDBRepo) EnableTwoFactor(userID, secret string, step int64, recoveryHashes []string) error {
+	return nil
+}
+
+func (m *testDBRepo) DisableTwoFactor(userID string) error {
+	return nil
+}
+
+func (m *testDBRepo) UseTOTPStep(userID string, step int64) error {
+	return nil
+}
+
+func (m *testDBRepo) UseRecoveryCode(userID, hash string) error {
+	return nil
+}
+
+func (m *testDBRepo) StartTwoFactorChallenge(userID, challengeID string) error {
+	return nil
+}
+
+func (m *testDBRepo) AttemptTwoFactorChallenge(userID, challengeID string, maxAttempts int) error {
+	return nil
+}
+
+func (m *testDBRepo) EndTwoFactorChallenge(userID string) error {
+	return nil
+}
+
+func (m *testDBRepo) GetTwoFactorRoles() ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *testDBRepo) SetTwoFactorRoles(roles []string) error {
+	return nil
+}
+
+func (m *testDBRepo) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	return &models.User{}, nil
+}
+
+func (m *testDBRepo) LinkIdentity(userID, provider, subject string) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertOIDCState(s models.OIDCState) error {
+	return nil
+}
+
+func (m *testDBRepo) UseOIDCState(provider, state string) (*models.OIDCState, error) {
+	return &models.OIDCState{}, nil
+}
+
+func (m *testDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+	return nil
+}
+
+func (m *testDBRepo) GetAuditEntries(page, limit int) ([]*models.AuditEntry, error) {
+	return []*models.AuditEntry{}, nil
+}
+
+func (m *testDBRepo) PublishScheduledPosts(now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (m *testDBRepo) ReleaseLease(name, holder string) error {
 	return nil
 }
 
 func (m *testDBRepo) GetUserByEmail(email string) (*models.User, error) {
-	return nil, nil
+	return nil, mongo.ErrNoDocuments
 }
 
 func (m *testDBRepo) GetPostCreator(id string) (string, error) {
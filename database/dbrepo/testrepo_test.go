@@ -0,0 +1,128 @@
+package dbrepo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+func TestTestingRepoGetPosts(t *testing.T) {
+	repo := NewTestingRepo(&config.AppConfig{})
+
+	tests := []struct {
+		name   string
+		filter models.PostFilter
+		ids    []string
+	}{
+		{
+			name:   "published posts, the latest published first",
+			filter: models.PostFilter{},
+			ids:    []string{"000000000000000000000001", "000000000000000000000003", "000000000000000000000002", "000000000000000000000004"},
+		},
+		{
+			name:   "tag",
+			filter: models.PostFilter{Tag: "go"},
+			ids:    []string{"000000000000000000000001", "000000000000000000000002"},
+		},
+		{
+			name:   "category",
+			filter: models.PostFilter{Category: "food"},
+			ids:    []string{"000000000000000000000003"},
+		},
+		{
+			name:   "creator",
+			filter: models.PostFilter{Creator: "000000000000000000000102"},
+			ids:    []string{"000000000000000000000003", "000000000000000000000004"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := repo.GetPosts(tt.filter)
+			if err != nil {
+				t.Fatalf("GetPosts returned error: %v", err)
+			}
+
+			ids := []string{}
+			for _, post := range posts {
+				ids = append(ids, post.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("GetPosts(%+v) = %q, want %q", tt.filter, ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestTestingRepoSearchPosts(t *testing.T) {
+	repo := NewTestingRepo(&config.AppConfig{})
+
+	tests := []struct {
+		name        string
+		query       string
+		page, limit int
+		ids         []string
+	}{
+		{
+			name:  "title matches first, drafts and scheduled posts left out",
+			query: "go",
+			page:  1,
+			limit: 10,
+			ids:   []string{"000000000000000000000002", "000000000000000000000001", "000000000000000000000003"},
+		},
+		{
+			name:  "first page",
+			query: "go",
+			page:  1,
+			limit: 2,
+			ids:   []string{"000000000000000000000002", "000000000000000000000001"},
+		},
+		{
+			name:  "second page",
+			query: "go",
+			page:  2,
+			limit: 2,
+			ids:   []string{"000000000000000000000003"},
+		},
+		{
+			name:  "page after the results",
+			query: "go",
+			page:  3,
+			limit: 2,
+			ids:   []string{},
+		},
+		{
+			name:  "posts without status are searched",
+			query: "text index",
+			page:  1,
+			limit: 10,
+			ids:   []string{"000000000000000000000004"},
+		},
+		{
+			name:  "no match",
+			query: "rust",
+			page:  1,
+			limit: 10,
+			ids:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.SearchPosts(tt.query, tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("SearchPosts returned error: %v", err)
+			}
+
+			ids := []string{}
+			for _, result := range results {
+				ids = append(ids, result.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("SearchPosts(%q, %d, %d) = %q, want %q", tt.query, tt.page, tt.limit, ids, tt.ids)
+			}
+		})
+	}
+}
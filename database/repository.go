@@ -1,23 +1,108 @@
 package database
 
 import (
+	"time"
+
 	"github.com/schattenbrot/mini-blog-api/models"
 )
 
 // DatabaseRepo represents the database repository.
 type DatabaseRepo interface {
+	EnsureIndexes() error
+
 	InsertPost(p models.Post) (*string, error)
-	GetPosts() ([]*models.Post, error)
+	GetPosts(filter models.PostFilter) ([]*models.Post, error)
 	GetPostCreator(id string) (string, error)
 	GetPostById(id string) (*models.Post, error)
-	GetPostsByPage(page, limit int) ([]*models.Post, error)
-	UpdatePost(p models.Post) error
+	GetPostBySlug(slug string) (*models.Post, error)
+	GetPostsByPage(page, limit int, filter models.PostFilter) ([]*models.Post, error)
+	GetDraftsByCreator(creator string) ([]*models.Post, error)
+	UpdatePost(p models.Post, editor string) error
 	DeleteOnePost(id string) error
+	GetTagCounts() ([]*models.TagCount, error)
+	SearchPosts(query string, page, limit int) ([]*models.SearchResult, error)
+	PublishScheduledPosts(now time.Time) (int64, error)
+	GetDeletedPosts(creator string) ([]*models.Post, error)
+	RestorePost(id string) error
+
+	GetRevisions(postID string) ([]*models.Revision, error)
+	GetRevision(postID string, number int) (*models.Revision, error)
+
+	InsertComment(c models.Comment) (*string, error)
+	GetCommentsByPost(postID string) ([]*models.Comment, error)
+	GetCommentAuthor(id string) (string, error)
+	UpdateComment(c models.Comment) error
+	DeleteComment(postID, id string) error
+
+	AddReaction(postID, userID, kind string) error
+	RemoveReaction(postID, userID, kind string) error
+
+	InsertSeries(s models.Series) (*string, error)
+	GetAllSeries() ([]*models.Series, error)
+	GetSeriesById(id string) (*models.Series, error)
+	GetSeriesByPost(postID string) (*models.Series, error)
+	GetSeriesCreator(id string) (string, error)
+	UpdateSeries(s models.Series) error
+	DeleteSeries(id string) error
+
+	InsertMedia(m models.Media) (*string, error)
+	GetMediaById(id string) (*models.Media, error)
+	GetMediaOwner(id string) (string, error)
+	MediaExist(ids []string, owners []string) (bool, error)
+	DeleteMedia(id string) error
 
 	InsertUser(u models.User) (*string, error)
 	GetUserRoles(id string) ([]string, error)
 	GetUserById(id string) (*models.User, error)
+	GetUserProfile(id string) (*models.PublicProfile, error)
 	GetUserByEmail(email string) (*models.User, error)
 	UpdateUser(u models.User) error
-	DeleteUser(id string) error
+	DeleteUser(id, policy string) error
+	GetDeletedUsers() ([]*models.User, error)
+	RestoreUser(id string) error
+
+	PurgeDeleted(before time.Time) (int64, error)
+
+	SetEmailVerification(userID, tokenID string) error
+	VerifyEmail(userID, tokenID string) error
+	SetPasswordReset(userID, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, password string) error
+
+	GetUsers(query string, page, limit int) ([]*models.User, error)
+	SetUserRoles(id string, roles []string) error
+	SuspendUser(id string, s models.Suspension) error
+	UnsuspendUser(id string) error
+	RevokeSessions(id string) error
+
+	InsertSession(s models.Session, refreshHash string) (string, error)
+	RotateSession(refreshHash, newRefreshHash, userAgent, ip string) (*models.Session, error)
+	GetActiveSession(id string) (*models.Session, error)
+	GetActiveSessions(userID string) ([]*models.Session, error)
+	RevokeSession(userID, id string) error
+
+	InsertAPIKey(k models.APIKey, hash string) (string, error)
+	UseAPIKey(hash string) (*models.APIKey, error)
+	GetAPIKeys(userID string) ([]*models.APIKey, error)
+	DeleteAPIKey(userID, id string) error
+
+	SetTwoFactorPending(userID, secret string) error
+	EnableTwoFactor(userID, secret string, step int64, recoveryHashes []string) error
+	DisableTwoFactor(userID string) error
+	UseTOTPStep(userID string, step int64) error
+	UseRecoveryCode(userID, hash string) error
+	StartTwoFactorChallenge(userID, challengeID string) error
+	AttemptTwoFactorChallenge(userID, challengeID string, maxAttempts int) error
+	EndTwoFactorChallenge(userID string) error
+	GetTwoFactorRoles() ([]string, error)
+	SetTwoFactorRoles(roles []string) error
+
+	GetUserByIdentity(provider, subject string) (*models.User, error)
+	LinkIdentity(userID, provider, subject string) error
+	InsertOIDCState(s models.OIDCState) error
+	UseOIDCState(provider, state string) (*models.OIDCState, error)
+	InsertAuditEntry(e models.AuditEntry) error
+	GetAuditEntries(page, limit int) ([]*models.AuditEntry, error)
+
+	AcquireLease(name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(name, holder string) error
 }
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type fileMailer struct {
+	Dir    string
+	Logger *log.Logger
+}
+
+// NewFileMailer is the function for returning a mailer which writes every
+// email into a file of the directory instead of sending it. Without a
+// directory the emails are only written to the logger. It is meant for local
+// development and tests.
+func NewFileMailer(dir string, logger *log.Logger) (Mailer, error) {
+	if dir != "" {
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &fileMailer{
+		Dir:    dir,
+		Logger: logger,
+	}, nil
+}
+
+// Send writes the mail into a new file or the log.
+// Returns an error if any occurred.
+func (m *fileMailer) Send(mail Mail) error {
+	message := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", mail.To, mail.Subject, mail.Body)
+
+	if m.Dir == "" {
+		m.Logger.Print("mail:\n" + message)
+		return nil
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(message), 0o644)
+}
@@ -0,0 +1,13 @@
+package mailer
+
+// Mail describes an email sent by the app.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer represents a transport for sending emails.
+type Mailer interface {
+	Send(mail Mail) error
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type smtpMailer struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+// NewSMTPMailer is the function for returning a mailer which sends the emails
+// through an SMTP server. Without a username no authentication is used.
+func NewSMTPMailer(host string, port int, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &smtpMailer{
+		Addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		Auth: auth,
+		From: from,
+	}
+}
+
+// Send sends the mail through the SMTP server.
+// Returns an error if any occurred.
+func (m *smtpMailer) Send(mail Mail) error {
+	if strings.ContainsAny(mail.To+mail.Subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.From, mail.To, mail.Subject, mail.Body)
+
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{mail.To}, []byte(message))
+}
@@ -1,24 +1,127 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/schattenbrot/mini-blog-api/models"
 	"github.com/schattenbrot/mini-blog-api/utils"
 )
 
-// Auth checks if the requests is authorized to access the endpoint.
+// Auth checks if the requests is authorized to access the endpoint. Requests
+// are authenticated by an access token or a personal API key, whose scopes
+// have to allow the request method.
 func (m *Repository) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		issuer, err := utils.GetIssuerFromCookie(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+		auth, err := m.authenticate(r)
 		if err != nil {
 			notAuthenticated(w, err)
 			return
 		}
 
-		_, err = m.DB.GetUserById(issuer)
-		if err != nil {
-			notAuthenticated(w, err)
+		if auth.APIKey && !utils.ScopesAllowMethod(auth.Scopes, r.Method) {
+			setStatusForbidden(w)
+			return
+		}
+
+		next.ServeHTTP(w, utils.WithAuthentication(r, auth))
+	})
+}
+
+// Identify authenticates requests to public endpoints like Auth, so handlers
+// can show more to the requesting user. Requests without valid credentials
+// pass anonymously.
+func (m *Repository) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, err := m.authenticate(r)
+		if err != nil || (auth.APIKey && !utils.ScopesAllowMethod(auth.Scopes, r.Method)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, utils.WithAuthentication(r, auth))
+	})
+}
+
+// authenticate checks the access token or personal API key of the request.
+// Returns the authentication and an error if there is none or it is not
+// valid.
+func (m *Repository) authenticate(r *http.Request) (utils.Authentication, error) {
+	token, err := utils.GetToken(r, m.App.Config.Cookie.Name)
+	if err != nil {
+		return utils.Authentication{}, err
+	}
+
+	if strings.HasPrefix(token, models.APIKeyPrefix) {
+		return m.authenticateAPIKey(token)
+	}
+
+	return m.authenticateSession(r)
+}
+
+// authenticateSession checks the access token of the request and its session.
+// Returns the authentication and an error if it is not valid.
+func (m *Repository) authenticateSession(r *http.Request) (utils.Authentication, error) {
+	claims, err := utils.GetClaims(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+	if err != nil {
+		return utils.Authentication{}, err
+	}
+
+	user, err := m.DB.GetUserById(claims.Issuer)
+	if err != nil {
+		return utils.Authentication{}, err
+	}
+
+	if user.Suspension != nil {
+		return utils.Authentication{}, errors.New("user is suspended")
+	}
+
+	session, err := m.DB.GetActiveSession(claims.Id)
+	if err != nil || session.UserID != user.ID {
+		return utils.Authentication{}, errors.New("session is not active")
+	}
+
+	// sessions started before the last forced logout are invalid
+	if user.SessionsRevokedAt != nil && claims.IssuedAt < user.SessionsRevokedAt.Unix() {
+		return utils.Authentication{}, errors.New("session was revoked")
+	}
+
+	return utils.Authentication{UserID: user.ID}, nil
+}
+
+// authenticateAPIKey checks the personal API key.
+// Returns the authentication and an error if it is not valid.
+func (m *Repository) authenticateAPIKey(token string) (utils.Authentication, error) {
+	key, err := m.DB.UseAPIKey(utils.HashToken(token))
+	if err != nil {
+		return utils.Authentication{}, errors.New("api key is not valid")
+	}
+
+	user, err := m.DB.GetUserById(key.UserID)
+	if err != nil {
+		return utils.Authentication{}, err
+	}
+
+	if user.Suspension != nil {
+		return utils.Authentication{}, errors.New("user is suspended")
+	}
+
+	return utils.Authentication{
+		UserID: user.ID,
+		APIKey: true,
+		Scopes: key.Scopes,
+	}, nil
+}
+
+// RequireSession is a middleware to reject requests authenticated with an API
+// key, so keys can't manage sessions or other keys.
+func (m *Repository) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := utils.GetAuthentication(r)
+		if !ok || auth.APIKey {
+			setStatusForbidden(w)
 			return
 		}
 
@@ -33,73 +136,102 @@ func notAuthenticated(w http.ResponseWriter, err error) {
 	w.WriteHeader(statusCode)
 }
 
-// IsPostCreatorOrAdmin is a middleware to check if the user got the permission
-// to modify or delete the target post.
-func (m *Repository) IsPostCreatorOrAdmin(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		issuer, err := utils.GetIssuerFromCookie(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
-		if err != nil {
-			setStatusForbidden(w)
-			return
-		}
-
-		// check for creator
-		postID := chi.URLParam(r, "id")
-		creator, err := m.DB.GetPostCreator(postID)
-		if err == nil {
-			if issuer == creator {
-				next.ServeHTTP(w, r)
+// Require is a middleware to check if the roles of the user grant the given
+// permission.
+func (m *Repository) Require(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			issuer, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+			if err != nil {
+				setStatusForbidden(w)
 				return
 			}
-		}
 
-		// check for admin rights
-		userRoles, err := m.DB.GetUserRoles(issuer)
-		if err == nil {
-			for _, role := range userRoles {
-				if role == issuer {
-					next.ServeHTTP(w, r)
-					return
-				}
+			if !m.hasPermission(r, issuer, permission) {
+				setStatusForbidden(w)
+				return
 			}
-		}
 
-		setStatusForbidden(w)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsPostCreatorOr is a middleware to check if the user is the creator of the
+// target post or got the given permission.
+func (m *Repository) IsPostCreatorOr(permission string) func(http.Handler) http.Handler {
+	return m.isOwnerOr(permission, func(r *http.Request) (string, error) {
+		return m.DB.GetPostCreator(chi.URLParam(r, "id"))
 	})
 }
 
-// IsUserOrAdmin is a middleware to check if the user got the permission to
-// change or delete the target user.
-func (m *Repository) IsUserOrAdmin(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		issuer, err := utils.GetIssuerFromCookie(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
-		if err != nil {
-			setStatusForbidden(w)
-			return
-		}
+// IsCommentAuthorOr is a middleware to check if the user is the author of the
+// target comment or got the given permission.
+func (m *Repository) IsCommentAuthorOr(permission string) func(http.Handler) http.Handler {
+	return m.isOwnerOr(permission, func(r *http.Request) (string, error) {
+		return m.DB.GetCommentAuthor(chi.URLParam(r, "commentID"))
+	})
+}
 
-		// check if correct user
-		userID := chi.URLParam(r, "id")
-		if issuer == userID {
-			next.ServeHTTP(w, r)
-			return
-		}
+// IsMediaOwnerOr is a middleware to check if the user is the uploader of the
+// target media or got the given permission.
+func (m *Repository) IsMediaOwnerOr(permission string) func(http.Handler) http.Handler {
+	return m.isOwnerOr(permission, func(r *http.Request) (string, error) {
+		return m.DB.GetMediaOwner(chi.URLParam(r, "id"))
+	})
+}
 
-		// check for admin rights
-		userRoles, err := m.DB.GetUserRoles(issuer)
-		if err == nil {
-			for _, role := range userRoles {
-				if role == "admin" {
-					next.ServeHTTP(w, r)
-					return
-				}
-			}
-		}
+// IsSeriesCreatorOr is a middleware to check if the user is the creator of
+// the target series or got the given permission.
+func (m *Repository) IsSeriesCreatorOr(permission string) func(http.Handler) http.Handler {
+	return m.isOwnerOr(permission, func(r *http.Request) (string, error) {
+		return m.DB.GetSeriesCreator(chi.URLParam(r, "id"))
+	})
+}
 
-		setStatusForbidden(w)
+// IsUserOr is a middleware to check if the user is the target user or got the
+// given permission.
+func (m *Repository) IsUserOr(permission string) func(http.Handler) http.Handler {
+	return m.isOwnerOr(permission, func(r *http.Request) (string, error) {
+		return chi.URLParam(r, "id"), nil
 	})
 }
 
+// isOwnerOr creates a middleware which lets the owner of the target resource
+// pass, as well as users whose roles grant the given permission.
+func (m *Repository) isOwnerOr(permission string, owner func(r *http.Request) (string, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			issuer, err := utils.GetIssuer(r, m.App.Config.Cookie.Name, m.App.Config.JWT)
+			if err != nil {
+				setStatusForbidden(w)
+				return
+			}
+
+			// check for owner
+			ownerID, err := owner(r)
+			if err == nil && issuer == ownerID && utils.RequestScopesAllow(r, permission) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// check for permission
+			if m.hasPermission(r, issuer, permission) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setStatusForbidden(w)
+		})
+	}
+}
+
+// hasPermission checks if the roles of the user grant the permission and the
+// API key of the request, if any, allows it.
+func (m *Repository) hasPermission(r *http.Request, userID, permission string) bool {
+	return utils.RequestHasPermission(r, m.App.Config.Roles, m.DB.GetUserRoles, userID, permission)
+}
+
 // setStatusForbidden sets the status to StatusForbidden
 func setStatusForbidden(w http.ResponseWriter) {
 	statusCode := http.StatusForbidden
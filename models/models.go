@@ -2,22 +2,532 @@ package models
 
 import "time"
 
+// Post states describe the lifecycle of a post.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
+// User deletion policies decide what happens to the posts of a deleted user.
+const (
+	UserDeletionRefuse = "refuse"
+	UserDeletionGhost  = "ghost"
+	UserDeletionDelete = "delete"
+)
+
+// Built-in roles which can be given to a user. More roles can be defined in
+// the roles file of the configuration.
+const (
+	RoleUser      = "user"
+	RoleAuthor    = "author"
+	RoleEditor    = "editor"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+
+	// RoleUnverified replaces the roles of users whose email is not
+	// verified yet.
+	RoleUnverified = "unverified"
+)
+
+// Email verification modes decide how users with an unverified email are
+// treated.
+const (
+	EmailVerificationOff    = "off"
+	EmailVerificationLimit  = "limit"
+	EmailVerificationRefuse = "refuse"
+)
+
+// Permissions are granted to users by their roles.
+const (
+	PermAll              = "*"
+	PermPostCreate       = "post:create"
+	PermPostPublish      = "post:publish"
+	PermPostReadAny      = "post:read:any"
+	PermPostEditAny      = "post:edit:any"
+	PermPostDeleteAny    = "post:delete:any"
+	PermCommentCreate    = "comment:create"
+	PermCommentEditAny   = "comment:edit:any"
+	PermCommentDeleteAny = "comment:delete:any"
+	PermMediaUpload      = "media:upload"
+	PermMediaDeleteAny   = "media:delete:any"
+	PermSeriesCreate     = "series:create"
+	PermSeriesEditAny    = "series:edit:any"
+	PermUserList         = "user:list"
+	PermUserReadPrivate  = "user:read:private"
+	PermUserEditAny      = "user:edit:any"
+	PermUserDeleteAny    = "user:delete:any"
+	PermUserBan          = "user:ban"
+	PermUserRoles        = "user:roles"
+	PermAuditRead        = "audit:read"
+)
+
+// Permissions lists all permissions which can be granted to a role.
+var Permissions = []string{
+	PermAll,
+	PermPostCreate,
+	PermPostPublish,
+	PermPostReadAny,
+	PermPostEditAny,
+	PermPostDeleteAny,
+	PermCommentCreate,
+	PermCommentEditAny,
+	PermCommentDeleteAny,
+	PermMediaUpload,
+	PermMediaDeleteAny,
+	PermSeriesCreate,
+	PermSeriesEditAny,
+	PermUserList,
+	PermUserReadPrivate,
+	PermUserEditAny,
+	PermUserDeleteAny,
+	PermUserBan,
+	PermUserRoles,
+	PermAuditRead,
+}
+
+// IsPermission checks if the permission is one of the Permissions.
+func IsPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyPrefix starts every personal API key, which tells them apart from
+// access tokens.
+const APIKeyPrefix = "mbk_"
+
+// Scopes limit what a personal API key may do on behalf of its user.
+const (
+	ScopePostsRead    = "posts:read"
+	ScopePostsWrite   = "posts:write"
+	ScopePostsPublish = "posts:publish"
+	ScopeAdmin        = "admin"
+)
+
+// ScopePermissions maps the scopes to the permissions they allow. A key can
+// only use a permission if its scopes allow it and the roles of its user
+// grant it.
+var ScopePermissions = map[string][]string{
+	ScopePostsRead: {
+		PermPostReadAny,
+	},
+	ScopePostsWrite: {
+		PermPostCreate,
+		PermPostReadAny,
+		PermPostEditAny,
+		PermPostDeleteAny,
+		PermCommentCreate,
+		PermCommentEditAny,
+		PermCommentDeleteAny,
+		PermMediaUpload,
+		PermMediaDeleteAny,
+		PermSeriesCreate,
+		PermSeriesEditAny,
+	},
+	ScopePostsPublish: {
+		PermPostPublish,
+	},
+	ScopeAdmin: {
+		PermAll,
+	},
+}
+
+// ReactionKinds are the reactions which can be given to a post.
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// IsReactionKind checks if the kind is one of the ReactionKinds.
+func IsReactionKind(kind string) bool {
+	for _, k := range ReactionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
 // Post describes the globally used Post type.
 type Post struct {
-	ID        string    `json:"id,omitempty"`
-	Title     string    `json:"title,omitempty" validate:"omitempty,min=3,max=40"`
-	Text      string    `json:"text,omitempty" validate:"omitempty,min=5,max=700"`
-	Creator   string    `json:"user,omitempty" validate:"omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	ID        string            `json:"id,omitempty"`
+	Title     string            `json:"title,omitempty" validate:"omitempty,min=3,max=150"`
+	Slug      string            `json:"slug,omitempty" validate:"isdefault"`
+	Text      string            `json:"text,omitempty" validate:"omitempty,min=5,max=100000,maxlines=5000"`
+	HTML      string            `json:"html,omitempty" validate:"isdefault"`
+	TOC       []TOCEntry        `json:"toc,omitempty" validate:"isdefault"`
+	Creator   string            `json:"user,omitempty" validate:"omitempty"`
+	Tags      []string          `json:"tags,omitempty" validate:"omitempty,max=10,dive,tag"`
+	Media     []string          `json:"media,omitempty" validate:"omitempty,max=20,unique,dive,mongodb"`
+	Category  string            `json:"category,omitempty" validate:"omitempty,tag"`
+	Status    string            `json:"status,omitempty" validate:"omitempty,oneof=draft scheduled published archived"`
+	PublishAt time.Time         `json:"publish_at,omitempty"`
+	Comments  int               `json:"comment_count"`
+	Reactions map[string]int    `json:"reactions,omitempty" validate:"isdefault"`
+	Series    *SeriesNavigation `json:"series,omitempty" validate:"isdefault"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at,omitempty"`
+	DeletedAt *time.Time        `json:"deleted_at,omitempty" validate:"isdefault"`
+}
+
+// TOCEntry describes a heading in the table of contents of a post.
+type TOCEntry struct {
+	Level  int    `json:"level"`
+	Title  string `json:"title"`
+	Anchor string `json:"anchor"`
+}
+
+// IsPublic reports whether the post is visible to everyone at the given time.
+// Posts stored before the lifecycle existed carry no status and stay public.
+func (p *Post) IsPublic(now time.Time) bool {
+	if p.Status == "" {
+		return true
+	}
+	return p.Status == PostStatusPublished && !p.PublishAt.After(now)
 }
 
-// User describes the globally used User type.
+// PostFilter describes the optional filters of the post listings.
+type PostFilter struct {
+	Tag      string
+	Category string
+	Creator  string
+}
+
+// SearchResult describes a post found by a search together with its
+// relevance score and a highlighted snippet of the matching text.
+type SearchResult struct {
+	Post
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// TagCount describes how many published posts use a tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Comment describes a comment on a post. Replies point to their parent
+// comment and are nested into it when a post's comments are listed.
+type Comment struct {
+	ID        string     `json:"id,omitempty"`
+	PostID    string     `json:"post_id,omitempty"`
+	ParentID  string     `json:"parent_id,omitempty"`
+	Author    string     `json:"user,omitempty"`
+	Text      string     `json:"text" validate:"required,min=1,max=1000"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at,omitempty"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
+
+// Series describes an ordered collection of posts like a multi-part tutorial.
+type Series struct {
+	ID          string    `json:"id,omitempty"`
+	Title       string    `json:"title,omitempty" validate:"omitempty,min=3,max=150"`
+	Description string    `json:"description,omitempty" validate:"omitempty,max=2000"`
+	Creator     string    `json:"user,omitempty"`
+	Posts       []string  `json:"posts" validate:"omitempty,max=100,unique,dive,mongodb"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// SeriesNavigation describes the position of a post inside its series.
+type SeriesNavigation struct {
+	ID       string      `json:"id"`
+	Title    string      `json:"title"`
+	Position int         `json:"position"`
+	Total    int         `json:"total"`
+	Previous *SeriesLink `json:"previous,omitempty"`
+	Next     *SeriesLink `json:"next,omitempty"`
+}
+
+// SeriesLink describes a neighbouring post inside a series.
+type SeriesLink struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Slug  string `json:"slug,omitempty"`
+}
+
+// Media describes an uploaded image together with its thumbnails.
+type Media struct {
+	ID          string      `json:"id,omitempty"`
+	Owner       string      `json:"user,omitempty"`
+	Name        string      `json:"name"`
+	ContentType string      `json:"content_type"`
+	Size        int64       `json:"size"`
+	Width       int         `json:"width"`
+	Height      int         `json:"height"`
+	Key         string      `json:"-"`
+	Thumbnails  []Thumbnail `json:"thumbnails"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Thumbnail describes a resized version of an uploaded image.
+type Thumbnail struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"content_type"`
+	Key         string `json:"-"`
+}
+
+// User describes the globally used User type. It holds the password hash and
+// must never be sent to clients, use UserResponse instead.
 type User struct {
+	ID       string   `json:"id,omitempty"`
+	Name     string   `json:"name" validate:"omitempty,min=3,max=20"`
+	Email    string   `json:"email" validate:"omitempty,email"`
+	Password string   `json:"-" validate:"omitempty,min=8,max=24"`
+	Roles    []string `json:"roles" validate:"omitempty,dive,role"`
+	Profile
+	Suspension          *Suspension `json:"suspension,omitempty" validate:"isdefault"`
+	SessionsRevokedAt   *time.Time  `json:"-"`
+	EmailVerificationID string      `json:"-"`
+	TwoFactor           *TwoFactor  `json:"-"`
+	Identities          []Identity  `json:"-"`
+	CreatedAt           time.Time   `json:"created_at"`
+	DeletedAt           *time.Time  `json:"deleted_at,omitempty" validate:"isdefault"`
+}
+
+// EmailVerified reports whether the user confirmed its email. Users without
+// a pending verification count as verified.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerificationID == ""
+}
+
+// TwoFactorEnabled reports whether the user finished the enrolment of
+// two-factor authentication.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.EnabledAt != nil
+}
+
+// Identity describes the account of a user at an external OpenID Connect
+// provider.
+type Identity struct {
+	Provider string
+	Subject  string
+}
+
+// OIDCState describes a login started at an external OpenID Connect provider.
+// The nonce and the PKCE code verifier are only known to the server.
+type OIDCState struct {
+	State     string
+	Provider  string
+	Nonce     string
+	Verifier  string
+	ExpiresAt time.Time
+}
+
+// TwoFactor describes the TOTP two-factor authentication of a user.
+// RecoveryCodes is the number of unused recovery codes.
+type TwoFactor struct {
+	Secret        string
+	PendingSecret string
+	EnabledAt     *time.Time
+	RecoveryCodes int
+}
+
+// TwoFactorEnrolment describes the secret of a new two-factor enrolment.
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorCodeRequest describes the body of requests which need a TOTP or
+// recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=20"`
+}
+
+// TwoFactorLoginRequest describes the body of the second login step.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=20"`
+	ReturnToken    bool   `json:"return_token"`
+}
+
+// TwoFactorRolesRequest describes the body of the request choosing the roles
+// which need two-factor authentication.
+type TwoFactorRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,dive,role"`
+}
+
+// Suspension describes why and by whom a user was suspended.
+type Suspension struct {
+	Reason string    `json:"reason"`
+	By     string    `json:"by"`
+	At     time.Time `json:"at"`
+}
+
+// RolesRequest describes the body of the request assigning roles to a user.
+type RolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,role"`
+}
+
+// TokenRequest describes the body of requests confirming a signed token.
+type TokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// EmailRequest describes the body of requests which only need an email.
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetRequest describes the body of the request setting a new
+// password with a password reset token.
+type PasswordResetRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// SuspensionRequest describes the body of the request suspending a user.
+type SuspensionRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// AuditEntry describes an action of an admin.
+type AuditEntry struct {
+	ID        string                 `json:"id"`
+	Actor     string                 `json:"actor"`
+	Action    string                 `json:"action"`
+	Target    string                 `json:"target"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Session describes a login of a user on a device. Current marks the session
+// of the request.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// APIKey describes a personal API key of a user. The key itself is only
+// known when it is created, Hint helps to recognize it afterwards.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	Key        string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyRequest describes the body of the request creating a personal API
+// key.
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=50"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write posts:publish admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// UserRequest describes the body of the requests creating or updating a user.
+type UserRequest struct {
+	Name     string `json:"name" validate:"omitempty,min=3,max=20"`
+	Email    string `json:"email" validate:"omitempty,email"`
+	Password string `json:"password" validate:"omitempty,min=8,max=24"`
+	Profile
+}
+
+// ToUser converts the request into a user.
+func (u UserRequest) ToUser() User {
+	return User{
+		Name:     u.Name,
+		Email:    u.Email,
+		Password: u.Password,
+		Profile:  u.Profile,
+	}
+}
+
+// UserResponse describes a user as it is sent to clients. It never contains
+// the password and only contains the email, its verification and the
+// suspension if the requester may see them.
+type UserResponse struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email,omitempty"`
+	Roles []string `json:"roles"`
+	Profile
+	Suspension    *Suspension `json:"suspension,omitempty"`
+	EmailVerified *bool       `json:"email_verified,omitempty"`
+	TwoFactor     *bool       `json:"two_factor_enabled,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	DeletedAt     *time.Time  `json:"deleted_at,omitempty"`
+}
+
+// NewUserResponse creates the response for a user. The email, its
+// verification, the two-factor state and the suspension are only added if
+// showPrivate is set.
+func NewUserResponse(u *User, showPrivate bool) UserResponse {
+	response := UserResponse{
+		ID:        u.ID,
+		Name:      u.Name,
+		Roles:     u.Roles,
+		Profile:   u.Profile,
+		CreatedAt: u.CreatedAt,
+		DeletedAt: u.DeletedAt,
+	}
+	if showPrivate {
+		response.Email = u.Email
+		response.Suspension = u.Suspension
+		verified := u.EmailVerified()
+		response.EmailVerified = &verified
+		twoFactor := u.TwoFactorEnabled()
+		response.TwoFactor = &twoFactor
+	}
+
+	return response
+}
+
+// Profile describes the public profile fields of a user.
+type Profile struct {
+	DisplayName string   `json:"display_name,omitempty" validate:"omitempty,max=50"`
+	Bio         string   `json:"bio,omitempty" validate:"omitempty,max=1000"`
+	Avatar      string   `json:"avatar,omitempty" validate:"omitempty,mongodb"`
+	Links       []string `json:"links,omitempty" validate:"max=5,dive,url,max=200"`
+}
+
+// PublicProfile describes the publicly visible part of a user.
+type PublicProfile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Profile
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Revision describes a stored version of the content of a post.
+type Revision struct {
 	ID        string    `json:"id,omitempty"`
-	Name      string    `json:"name" validate:"omitempty,min=3,max=20"`
-	Email     string    `json:"email" validate:"omitempty,email"`
-	Password  string    `json:"password,omitempty" validate:"omitempty,min=8,max=24"`
-	Roles     []string  `json:"roles" validate:"omitempty,dive,eq=user"`
+	PostID    string    `json:"post_id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	Editor    string    `json:"editor"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// DiffLine describes a single line of a text diff.
+// Op is one of "equal", "insert" or "delete".
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionDiff describes the differences between two revisions of a post.
+type RevisionDiff struct {
+	From  int        `json:"from"`
+	To    int        `json:"to"`
+	Title []DiffLine `json:"title"`
+	Text  []DiffLine `json:"text"`
+}
@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestVerify(t *testing.T) {
+	provider := newTestProvider(t)
+
+	unpublished, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token func(claims jwt.MapClaims) string
+		ok    bool
+	}{
+		{"valid", func(c jwt.MapClaims) string {
+			return provider.sign(t, "key-1", c)
+		}, true},
+		{"wrong issuer", func(c jwt.MapClaims) string {
+			c["iss"] = "https://impostor.example"
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"wrong audience", func(c jwt.MapClaims) string {
+			c["aud"] = "other-client"
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"audience list with client", func(c jwt.MapClaims) string {
+			c["aud"] = []string{"other-client", testClientID}
+			c["azp"] = testClientID
+			return provider.sign(t, "key-1", c)
+		}, true},
+		{"audience list without azp", func(c jwt.MapClaims) string {
+			c["aud"] = []string{"other-client", testClientID}
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"audience list authorized for another client", func(c jwt.MapClaims) string {
+			c["aud"] = []string{"other-client", testClientID}
+			c["azp"] = "other-client"
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"wrong nonce", func(c jwt.MapClaims) string {
+			c["nonce"] = "nonce-2"
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"no nonce", func(c jwt.MapClaims) string {
+			delete(c, "nonce")
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"no subject", func(c jwt.MapClaims) string {
+			delete(c, "sub")
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"expired", func(c jwt.MapClaims) string {
+			c["exp"] = now.Add(-2 * clockSkew).Unix()
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"expired within clock skew", func(c jwt.MapClaims) string {
+			c["exp"] = now.Add(-clockSkew / 2).Unix()
+			return provider.sign(t, "key-1", c)
+		}, true},
+		{"no expiry", func(c jwt.MapClaims) string {
+			delete(c, "exp")
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"issued in the future", func(c jwt.MapClaims) string {
+			c["iat"] = now.Add(2 * clockSkew).Unix()
+			return provider.sign(t, "key-1", c)
+		}, false},
+		{"signed by an unpublished key", func(c jwt.MapClaims) string {
+			return signWith(t, unpublished, "key-1", c)
+		}, false},
+		{"unknown key ID", func(c jwt.MapClaims) string {
+			return signWith(t, unpublished, "key-2", c)
+		}, false},
+		{"no key ID with a single key", func(c jwt.MapClaims) string {
+			return signWith(t, provider.keys["key-1"], "", c)
+		}, true},
+		{"HMAC with the client secret", func(c jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+			token.Header["kid"] = "key-1"
+			signed, err := token.SignedString([]byte(testClientSecret))
+			if err != nil {
+				t.Fatalf("could not sign token: %v", err)
+			}
+			return signed
+		}, false},
+		{"unsigned", func(c jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, c)
+			signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatalf("could not sign token: %v", err)
+			}
+			return signed
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := provider.newClient()
+
+			claims, err := client.Verify(context.Background(), tt.token(provider.claims("nonce-1")), "nonce-1")
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("Verify accepted the token")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Verify returned error: %v", err)
+			}
+			if claims.Subject != "subject-1" || claims.Email != "user@example.com" || !claims.EmailVerified || claims.Name != "Test User" {
+				t.Errorf("Verify returned claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	provider := newTestProvider(t)
+	client := provider.newClient()
+
+	_, err := client.Verify(context.Background(), provider.sign(t, "key-1", provider.claims("nonce-1")), "nonce-1")
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+
+	// the client caches key-1 and has to fetch the keys again for key-2
+	provider.addKey(t, "key-2")
+
+	_, err = client.Verify(context.Background(), provider.sign(t, "key-2", provider.claims("nonce-1")), "nonce-1")
+	if err != nil {
+		t.Errorf("Verify rejected a token of a rotated key: %v", err)
+	}
+}
+
+func TestAudienceUnmarshal(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+		ok   bool
+	}{
+		{`"client"`, []string{"client"}, true},
+		{`["client", "other"]`, []string{"client", "other"}, true},
+		{`42`, nil, false},
+	}
+
+	for _, tt := range tests {
+		var a audience
+		err := a.UnmarshalJSON([]byte(tt.data))
+		if (err == nil) != tt.ok {
+			t.Errorf("UnmarshalJSON(%s) error = %v, want ok %t", tt.data, err, tt.ok)
+			continue
+		}
+		if len(a) != len(tt.want) {
+			t.Errorf("UnmarshalJSON(%s) = %v, want %v", tt.data, a, tt.want)
+			continue
+		}
+		for i := range a {
+			if a[i] != tt.want[i] {
+				t.Errorf("UnmarshalJSON(%s) = %v, want %v", tt.data, a, tt.want)
+			}
+		}
+	}
+}
+
+// signWith signs the claims with the key under the key ID.
+func signWith(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+
+	return signed
+}
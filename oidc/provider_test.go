@@ -0,0 +1,324 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testClientID     = "mini-blog"
+	testClientSecret = "client secret"
+	testRedirectURL  = "http://localhost:4000/v1/users/oidc/test/callback"
+)
+
+// testProvider is a local stand-in for an OpenID Connect provider. It serves
+// the discovery document, its signing keys and a token endpoint which hands
+// out the ID token for a code after checking the PKCE verifier.
+type testProvider struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	keys  map[string]*rsa.PrivateKey
+	codes map[string]testCode
+}
+
+// testCode is an authorization code issued by the test provider.
+type testCode struct {
+	challenge string
+	idToken   string
+}
+
+// newTestProvider starts a test provider with one signing key with the ID
+// "key-1".
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+
+	p := &testProvider{
+		keys:  map[string]*rsa.PrivateKey{},
+		codes: map[string]testCode{},
+	}
+	p.addKey(t, "key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", p.serveKeys)
+	mux.HandleFunc("/token", p.serveToken)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+// addKey creates a new signing key, which the provider publishes from now on.
+func (p *testProvider) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	p.mu.Lock()
+	p.keys[kid] = key
+	p.mu.Unlock()
+
+	return key
+}
+
+// serveKeys serves the public signing keys as JWKS.
+func (p *testProvider) serveKeys(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := []map[string]string{}
+	for kid, key := range p.keys {
+		keys = append(keys, map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// serveToken redeems an authorization code of a client which authenticates
+// with its secret and presents the PKCE verifier of the code.
+func (p *testProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if !ok || clientID != testClientID || clientSecret != testClientSecret {
+		tokenError("invalid_client")
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != testRedirectURL {
+		tokenError("invalid_request")
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	if !ok || CodeChallenge(r.PostFormValue("code_verifier")) != code.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     code.idToken,
+	})
+}
+
+// issueCode creates an authorization code for the ID token, bound to the
+// PKCE challenge.
+func (p *testProvider) issueCode(code, challenge, idToken string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.codes[code] = testCode{challenge: challenge, idToken: idToken}
+}
+
+// claims returns valid claims of an ID token for the test client.
+func (p *testProvider) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+
+	return jwt.MapClaims{
+		"iss":            p.URL,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+	}
+}
+
+// sign signs the claims with the key of the ID.
+func (p *testProvider) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	p.mu.Lock()
+	key := p.keys[kid]
+	p.mu.Unlock()
+
+	return signWith(t, key, kid, claims)
+}
+
+// newClient creates a provider for the test client at the test provider.
+func (p *testProvider) newClient() *Provider {
+	return NewProvider("test", p.URL, testClientID, testClientSecret, testRedirectURL, []string{"email", "profile"})
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// the example of RFC 7636 appendix B
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got != want {
+		t.Errorf("CodeChallenge = %s, want %s", got, want)
+	}
+}
+
+func TestNewProviderScopes(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		want   string
+	}{
+		{nil, "openid"},
+		{[]string{"email"}, "openid email"},
+		{[]string{"email", "openid", "profile"}, "email openid profile"},
+	}
+
+	for _, tt := range tests {
+		p := NewProvider("test", "https://issuer.example/", testClientID, testClientSecret, testRedirectURL, tt.scopes)
+		if got := strings.Join(p.Scopes, " "); got != tt.want {
+			t.Errorf("NewProvider scopes %v = %q, want %q", tt.scopes, got, tt.want)
+		}
+		if p.Issuer != "https://issuer.example" {
+			t.Errorf("NewProvider issuer = %q, want it without trailing slash", p.Issuer)
+		}
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	provider := newTestProvider(t)
+	client := provider.newClient()
+
+	authURL, err := client.AuthCodeURL(context.Background(), "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned an invalid URL: %v", err)
+	}
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != provider.URL+"/authorize" {
+		t.Errorf("AuthCodeURL endpoint = %s, want %s", got, provider.URL+"/authorize")
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        CodeChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	query := parsed.Query()
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("AuthCodeURL %s = %q, want %q", key, query.Get(key), value)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	provider := newTestProvider(t)
+
+	// the discovery document names the test provider as issuer
+	impostor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, provider.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer impostor.Close()
+
+	client := NewProvider("test", impostor.URL, testClientID, testClientSecret, testRedirectURL, nil)
+
+	_, err := client.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err == nil {
+		t.Error("AuthCodeURL accepted a provider with another issuer")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	provider := newTestProvider(t)
+	idToken := provider.sign(t, "key-1", provider.claims("nonce-1"))
+
+	tests := []struct {
+		name         string
+		code         string
+		verifier     string
+		clientSecret string
+		ok           bool
+	}{
+		{"valid", "code-1", "verifier-1", testClientSecret, true},
+		{"wrong verifier", "code-1", "verifier-2", testClientSecret, false},
+		{"unknown code", "code-2", "verifier-1", testClientSecret, false},
+		{"wrong client secret", "code-1", "verifier-1", "guessed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.issueCode("code-1", CodeChallenge("verifier-1"), idToken)
+
+			client := provider.newClient()
+			client.ClientSecret = tt.clientSecret
+
+			got, err := client.Exchange(context.Background(), tt.code, tt.verifier)
+			if tt.ok {
+				if err != nil || got != idToken {
+					t.Errorf("Exchange = %q, %v, want the ID token", got, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("Exchange succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestExchangeCodeOnlyOnce(t *testing.T) {
+	provider := newTestProvider(t)
+	client := provider.newClient()
+
+	provider.issueCode("code-1", CodeChallenge("verifier-1"), provider.sign(t, "key-1", provider.claims("nonce-1")))
+
+	_, err := client.Exchange(context.Background(), "code-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+
+	_, err = client.Exchange(context.Background(), "code-1", "verifier-1")
+	if err == nil {
+		t.Error("Exchange redeemed a code twice")
+	}
+}
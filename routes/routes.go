@@ -5,6 +5,7 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/schattenbrot/mini-blog-api/controllers"
 	"github.com/schattenbrot/mini-blog-api/middlewares"
+	"github.com/schattenbrot/mini-blog-api/models"
 )
 
 // Routes returns a fully configured Mux of the chi-router.
@@ -13,16 +14,23 @@ func Routes(corsAllowedOrigins []string) *chi.Mux {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   corsAllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	r.Get("/", controllers.Repo.StatusHandler)
+	r.Get("/feed.rss", controllers.Repo.RSSFeed)
+	r.Get("/feed.atom", controllers.Repo.AtomFeed)
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Route("/posts", postRouter)
 		r.Route("/users", userRouter)
+		r.Get("/tags", controllers.Repo.GetTags)
+		r.Route("/media", mediaRouter)
+		r.Route("/series", seriesRouter)
+		r.Route("/admin", adminRouter)
 	})
 
 	return r
@@ -31,19 +39,100 @@ func Routes(corsAllowedOrigins []string) *chi.Mux {
 func postRouter(r chi.Router) {
 	r.Get("/", controllers.Repo.GetAllPosts)
 	r.Get("/paging", controllers.Repo.GetPaginatedPosts)
-	r.Get("/{id}", controllers.Repo.GetPostById)
+	r.Get("/search", controllers.Repo.SearchPosts)
+	r.With(middlewares.Repo.Identify).Get("/{id}", controllers.Repo.GetPostById)
+	r.With(middlewares.Repo.Identify).Get("/by-slug/{slug}", controllers.Repo.GetPostBySlug)
 
-	r.With(middlewares.Repo.Auth).Post("/", controllers.Repo.InsertPost)
-	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsPostCreatorOrAdmin).Patch("/{id}", controllers.Repo.UpdatePostById)
-	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsPostCreatorOrAdmin).Delete("/{id}", controllers.Repo.DeletePost)
+	r.With(middlewares.Repo.Auth).Get("/drafts", controllers.Repo.GetDrafts)
+	r.With(middlewares.Repo.Auth).Get("/trash", controllers.Repo.GetPostTrash)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.Require(models.PermPostCreate)).Post("/", controllers.Repo.InsertPost)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsPostCreatorOr(models.PermPostEditAny)).Patch("/{id}", controllers.Repo.UpdatePostById)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsPostCreatorOr(models.PermPostDeleteAny)).Delete("/{id}", controllers.Repo.DeletePost)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsPostCreatorOr(models.PermPostDeleteAny)).Post("/{id}/restore", controllers.Repo.RestorePost)
+
+	r.Route("/{id}/revisions", revisionRouter)
+	r.Route("/{id}/comments", commentRouter)
+
+	r.With(middlewares.Repo.Auth).Put("/{id}/reactions/{kind}", controllers.Repo.AddReaction)
+	r.With(middlewares.Repo.Auth).Delete("/{id}/reactions/{kind}", controllers.Repo.RemoveReaction)
+}
+
+func commentRouter(r chi.Router) {
+	r.With(middlewares.Repo.Identify).Get("/", controllers.Repo.GetComments)
+
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.Require(models.PermCommentCreate)).Post("/", controllers.Repo.InsertComment)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsCommentAuthorOr(models.PermCommentEditAny)).Patch("/{commentID}", controllers.Repo.UpdateCommentById)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsCommentAuthorOr(models.PermCommentDeleteAny)).Delete("/{commentID}", controllers.Repo.DeleteComment)
+}
+
+func revisionRouter(r chi.Router) {
+	r.Use(middlewares.Repo.Auth)
+	r.Use(middlewares.Repo.IsPostCreatorOr(models.PermPostEditAny))
+
+	r.Get("/", controllers.Repo.GetRevisions)
+	r.Get("/diff", controllers.Repo.GetRevisionDiff)
+	r.Get("/{rev}", controllers.Repo.GetRevision)
+	r.Post("/{rev}/restore", controllers.Repo.RestoreRevision)
 }
 
 func userRouter(r chi.Router) {
 	r.Post("/", controllers.Repo.InsertUser)
 	r.Post("/login", controllers.Repo.Login)
+	r.Post("/login/2fa", controllers.Repo.LoginTwoFactor)
+	r.Get("/oidc/{provider}", controllers.Repo.OIDCLogin)
+	r.Get("/oidc/{provider}/callback", controllers.Repo.OIDCCallback)
+	r.Post("/refresh", controllers.Repo.Refresh)
+	r.Post("/verify", controllers.Repo.VerifyEmail)
+	r.Post("/verify/resend", controllers.Repo.ResendVerification)
+	r.Post("/password/forgot", controllers.Repo.ForgotPassword)
+	r.Post("/password/reset", controllers.Repo.ResetPassword)
+	r.Get("/{id}/profile", controllers.Repo.GetUserProfile)
+	r.Get("/{id}/posts", controllers.Repo.GetUserPosts)
 
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.Require(models.PermUserDeleteAny)).Get("/trash", controllers.Repo.GetUserTrash)
 	r.With(middlewares.Repo.Auth).Get("/{id}", controllers.Repo.GetUserById)
-	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsUserOrAdmin).Patch("/{id}", controllers.Repo.UpdateUserById)
-	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsUserOrAdmin).Delete("/{id}", controllers.Repo.DeleteUser)
-	r.With(middlewares.Repo.Auth).Get("/logout", controllers.Repo.Logout)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsUserOr(models.PermUserEditAny)).Patch("/{id}", controllers.Repo.UpdateUserById)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsUserOr(models.PermUserDeleteAny)).Delete("/{id}", controllers.Repo.DeleteUser)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.Require(models.PermUserDeleteAny)).Post("/{id}/restore", controllers.Repo.RestoreUser)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Get("/logout", controllers.Repo.Logout)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Get("/me/sessions", controllers.Repo.GetSessions)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Delete("/me/sessions", controllers.Repo.RevokeSessions)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Delete("/me/sessions/{sessionID}", controllers.Repo.RevokeSession)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Get("/me/keys", controllers.Repo.GetAPIKeys)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Post("/me/keys", controllers.Repo.CreateAPIKey)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Delete("/me/keys/{keyID}", controllers.Repo.DeleteAPIKey)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Post("/me/2fa", controllers.Repo.EnrollTwoFactor)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Post("/me/2fa/confirm", controllers.Repo.ConfirmTwoFactor)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.RequireSession).Delete("/me/2fa", controllers.Repo.DisableTwoFactor)
+}
+
+func mediaRouter(r chi.Router) {
+	r.Get("/{id}", controllers.Repo.GetMediaById)
+	r.Get("/{id}/file", controllers.Repo.GetMediaFile)
+
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.Require(models.PermMediaUpload)).Post("/", controllers.Repo.UploadMedia)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsMediaOwnerOr(models.PermMediaDeleteAny)).Delete("/{id}", controllers.Repo.DeleteMedia)
+}
+
+func seriesRouter(r chi.Router) {
+	r.Get("/", controllers.Repo.GetAllSeries)
+	r.Get("/{id}", controllers.Repo.GetSeriesById)
+
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.Require(models.PermSeriesCreate)).Post("/", controllers.Repo.InsertSeries)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsSeriesCreatorOr(models.PermSeriesEditAny)).Patch("/{id}", controllers.Repo.UpdateSeriesById)
+	r.With(middlewares.Repo.Auth).With(middlewares.Repo.IsSeriesCreatorOr(models.PermSeriesEditAny)).Delete("/{id}", controllers.Repo.DeleteSeries)
+}
+
+func adminRouter(r chi.Router) {
+	r.Use(middlewares.Repo.Auth)
+
+	r.With(middlewares.Repo.Require(models.PermUserList)).Get("/users", controllers.Repo.AdminGetUsers)
+	r.With(middlewares.Repo.Require(models.PermUserRoles)).Put("/users/{id}/roles", controllers.Repo.AdminSetUserRoles)
+	r.With(middlewares.Repo.Require(models.PermUserBan)).Post("/users/{id}/suspension", controllers.Repo.AdminSuspendUser)
+	r.With(middlewares.Repo.Require(models.PermUserBan)).Delete("/users/{id}/suspension", controllers.Repo.AdminUnsuspendUser)
+	r.With(middlewares.Repo.Require(models.PermUserBan)).Post("/users/{id}/logout", controllers.Repo.AdminLogoutUser)
+	r.With(middlewares.Repo.Require(models.PermUserEditAny)).Delete("/users/{id}/2fa", controllers.Repo.AdminDisableTwoFactor)
+	r.With(middlewares.Repo.Require(models.PermUserRoles)).Get("/2fa/roles", controllers.Repo.AdminGetTwoFactorRoles)
+	r.With(middlewares.Repo.Require(models.PermUserRoles)).Put("/2fa/roles", controllers.Repo.AdminSetTwoFactorRoles)
+	r.With(middlewares.Repo.Require(models.PermAuditRead)).Get("/audit", controllers.Repo.AdminGetAuditLog)
 }
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var bucketName = "media"
+
+type gridFSStorage struct {
+	DB *mongo.Database
+}
+
+// NewGridFSStorage is the function for returning a storage which keeps the
+// files in the GridFS bucket "media" of the given database.
+func NewGridFSStorage(db *mongo.Database) Storage {
+	return &gridFSStorage{
+		DB: db,
+	}
+}
+
+// bucket opens the GridFS bucket. A new bucket is opened for every operation
+// since the deadlines of a bucket are not safe for concurrent use.
+func (s *gridFSStorage) bucket(timeout time.Duration) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(s.DB, &options.BucketOptions{Name: &bucketName})
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	bucket.SetReadDeadline(deadline)
+	bucket.SetWriteDeadline(deadline)
+
+	return bucket, nil
+}
+
+// Save stores the content of the reader under the given key.
+// Returns an error if any occurred.
+func (s *gridFSStorage) Save(key string, r io.Reader) error {
+	bucket, err := s.bucket(30 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	return bucket.UploadFromStreamWithID(key, key, r)
+}
+
+// Open opens the file stored under the given key.
+// Returns the file and an error if any occurred.
+func (s *gridFSStorage) Open(key string) (io.ReadCloser, error) {
+	bucket, err := s.bucket(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := bucket.OpenDownloadStream(key)
+	if err != nil {
+		if err == gridfs.ErrFileNotFound {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// Delete deletes the file stored under the given key.
+// Returns an error if any occurred.
+func (s *gridFSStorage) Delete(key string) error {
+	bucket, err := s.bucket(10 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	err = bucket.Delete(key)
+	if err == gridfs.ErrFileNotFound {
+		return ErrFileNotFound
+	}
+
+	return err
+}
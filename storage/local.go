@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type localStorage struct {
+	Dir string
+}
+
+// NewLocalStorage is the function for returning a storage which keeps the
+// files in a directory of the local filesystem.
+func NewLocalStorage(dir string) (Storage, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localStorage{
+		Dir: dir,
+	}, nil
+}
+
+// path returns the path of the file with the given key. Keys may not leave
+// the storage directory.
+func (s *localStorage) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", errors.New("invalid file key")
+	}
+	return filepath.Join(s.Dir, key), nil
+}
+
+// Save stores the content of the reader under the given key.
+// Returns an error if any occurred.
+func (s *localStorage) Save(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return file.Close()
+}
+
+// Open opens the file stored under the given key.
+// Returns the file and an error if any occurred.
+func (s *localStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Delete deletes the file stored under the given key.
+// Returns an error if any occurred.
+func (s *localStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	return err
+}
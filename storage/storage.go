@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrFileNotFound is returned when a requested file does not exist.
+var ErrFileNotFound = errors.New("file not found")
+
+// Storage represents a storage for uploaded files.
+type Storage interface {
+	Save(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Authentication describes who sent an authenticated request. Requests
+// authenticated with a personal API key are limited to the scopes of the key.
+type Authentication struct {
+	UserID string
+	APIKey bool
+	Scopes []string
+}
+
+// authenticationKey is the context key of the Authentication of a request.
+type authenticationKey struct{}
+
+// WithAuthentication returns a copy of the request carrying the
+// authentication.
+func WithAuthentication(r *http.Request, auth Authentication) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authenticationKey{}, auth))
+}
+
+// GetAuthentication retrieves the authentication of a request which passed
+// the Auth middleware.
+// Returns the authentication and whether the request carried one.
+func GetAuthentication(r *http.Request) (Authentication, bool) {
+	auth, ok := r.Context().Value(authenticationKey{}).(Authentication)
+	return auth, ok
+}
+
+// GetIssuer is a helper function that takes a request and tht
+// JWT_SECRET_TOKEN to retrieve the issuer and an error if any occured.
+// The issuer of a request which passed the Auth middleware is taken from its
+// authentication, which also covers API keys.
+func GetIssuer(r *http.Request, cookieName string, jwtSecret []byte) (string, error) {
+	if auth, ok := GetAuthentication(r); ok {
+		return auth.UserID, nil
+	}
+
+	claims, err := GetClaims(r, cookieName, jwtSecret)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Issuer, nil
+}
+
+// GetClaims is a helper function that takes a request and the
+// JWT_SECRET_TOKEN to retrieve all claims of the token and an error if any
+// occured.
+func GetClaims(r *http.Request, cookieName string, jwtSecret []byte) (*jwt.StandardClaims, error) {
+	tokenString, err := GetToken(r, cookieName)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*jwt.StandardClaims)
+	return claims, nil
+}
+
+// GetToken retrieves the access token of a request. A token in the
+// Authorization header takes precedence over the cookie.
+// Returns the token and an error if any occured.
+func GetToken(r *http.Request, cookieName string) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	if authorization != "" {
+		parts := strings.SplitN(authorization, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+			return "", errors.New("authorization header is not a bearer token")
+		}
+		return parts[1], nil
+	}
+
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return "", err
+	}
+
+	return cookie.Value, nil
+}
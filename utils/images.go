@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	// registers the gif decoder for image.Decode
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+)
+
+// IMAGE_MAX_PIXELS is the maximum number of pixels of an image which gets
+// decoded to protect against decompression bombs.
+const IMAGE_MAX_PIXELS = 50_000_000
+
+// ImageTypes maps the allowed image content types to their file extensions.
+var ImageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+var errInvalidJPEG = errors.New("invalid jpeg")
+
+// StripEXIF removes all APP1 segments, which carry the EXIF and XMP metadata,
+// from a JPEG without re-encoding the image.
+// Returns the stripped JPEG and an error if any occurred.
+func StripEXIF(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errInvalidJPEG
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, errInvalidJPEG
+		}
+		marker := data[pos+1]
+
+		// the entropy coded image data starts after the start of scan
+		// segment and needs no further inspection
+		if marker == 0xDA {
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errInvalidJPEG
+		}
+
+		if marker != 0xE1 {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	return nil, errInvalidJPEG
+}
+
+// DecodeImage decodes an image after checking that its dimensions stay below
+// IMAGE_MAX_PIXELS.
+// Returns the image and an error if any occurred.
+func DecodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > IMAGE_MAX_PIXELS {
+		return nil, errors.New("image dimensions are too large")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Thumbnail scales an image down to the given width keeping its aspect ratio.
+func Thumbnail(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Over, nil)
+
+	return thumb
+}
+
+// EncodeImage encodes an image as JPEG if the content type is image/jpeg and
+// as PNG otherwise.
+// Returns the encoded image, its content type and an error if any occurred.
+func EncodeImage(img image.Image, contentType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	if contentType == "image/jpeg" {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "image/png", err
+}
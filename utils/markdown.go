@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/schattenbrot/mini-blog-api/models"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+var sanitizer = newSanitizer()
+
+// newSanitizer creates the HTML policy for rendered posts. It extends the
+// policy for user generated content by the language classes of code blocks
+// and the ids of headings used as anchors.
+func newSanitizer() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+#-]+$`)).OnElements("code")
+	policy.AllowAttrs("id").Matching(regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	return policy
+}
+
+// RenderMarkdown renders the markdown source of a post to sanitized HTML.
+// Headings get ids to be used as anchors and are collected into a table of
+// contents.
+// Returns the HTML, the table of contents and an error if any occurred.
+func RenderMarkdown(source string) (string, []models.TOCEntry, error) {
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	toc := []models.TOCEntry{}
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		id, _ := heading.AttributeString("id")
+		anchor, _ := id.([]byte)
+		toc = append(toc, models.TOCEntry{
+			Level:  heading.Level,
+			Title:  string(heading.Text(src)),
+			Anchor: string(anchor),
+		})
+
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	err = markdown.Renderer().Render(&buf, src, doc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sanitizer.Sanitize(buf.String()), toc, nil
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+// htmlTag matches the tags of rendered HTML. Text between them is escaped, so
+// payloads there are harmless.
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// eventAttribute matches event handler attributes like onclick inside a tag.
+var eventAttribute = regexp.MustCompile(`\son[a-z]+\s*=`)
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"script element", "Hello <script>alert(1)</script> world"},
+		{"script block", "<script>\nalert(1)\n</script>"},
+		{"script with attributes", `<script src="https://evil.example/x.js"></script>`},
+		{"javascript link", "[click](javascript:alert(1))"},
+		{"javascript link upper case", "[click](JavaScript:alert(1))"},
+		{"javascript link encoded", "[click](javascript&#58;alert(1))"},
+		{"javascript autolink", "<javascript:alert(1)>"},
+		{"javascript reference link", "[click][x]\n\n[x]: javascript:alert(1)"},
+		{"vbscript link", "[click](vbscript:msgbox(1))"},
+		{"data link", "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)"},
+		{"javascript image", "![img](javascript:alert(1))"},
+		{"raw html link", `<a href="javascript:alert(1)">click</a>`},
+		{"event attribute on image", `<img src="x" onerror="alert(1)">`},
+		{"event attribute on inline element", `Hello <b onclick="alert(1)">world</b>`},
+		{"event attribute on div", `<div onmouseover="alert(1)">hover</div>`},
+		{"svg onload", `<svg onload="alert(1)"></svg>`},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`},
+		{"style element", `<style>body { display: none }</style>`},
+		{"style attribute", `<p style="background: url(javascript:alert(1))">x</p>`},
+		{"object", `<object data="evil.swf"></object>`},
+		{"form", `<form action="https://evil.example"><input name="password"></form>`},
+		{"meta refresh", `<meta http-equiv="refresh" content="0; url=https://evil.example">`},
+		{"heading attribute injection", "# Title {onclick=alert(1)}"},
+		{"code class injection", "```go\" onmouseover=\"alert(1)\nfmt.Println()\n```"},
+	}
+
+	forbidden := []string{
+		"<script", "</script", "javascript:", "vbscript:", "data:text/html",
+		"<iframe", "<svg", "<style", "style=", "<object", "<form", "<input",
+		"<meta",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, _, err := RenderMarkdown(tt.source)
+			if err != nil {
+				t.Fatalf("RenderMarkdown(%q) returned error: %v", tt.source, err)
+			}
+
+			for _, tag := range htmlTag.FindAllString(strings.ToLower(html), -1) {
+				for _, f := range forbidden {
+					if strings.Contains(tag, f) {
+						t.Errorf("RenderMarkdown(%q) = %q contains %q", tt.source, html, f)
+					}
+				}
+				if eventAttribute.MatchString(tag) {
+					t.Errorf("RenderMarkdown(%q) = %q contains an event attribute", tt.source, html)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownKeepsContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"emphasis", "Hello *world*", []string{"<em>world</em>"}},
+		{"safe link", "[blog](https://example.com/post)", []string{`href="https://example.com/post"`, ">blog</a>"}},
+		{"relative link", "[home](/v1/posts)", []string{`href="/v1/posts"`}},
+		{"image", "![cat](https://example.com/cat.png)", []string{`<img src="https://example.com/cat.png" alt="cat"`}},
+		{"code block language", "```go\nfmt.Println()\n```", []string{`<code class="language-go">`}},
+		{"heading anchor", "## Getting Started", []string{`<h2 id="getting-started">Getting Started</h2>`}},
+		{"table", "| a | b |\n|---|---|\n| 1 | 2 |", []string{"<table>", "<td>1</td>"}},
+		{"escaped text", "1 < 2 & 3 > 2", []string{"1 &lt; 2 &amp; 3 &gt; 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, _, err := RenderMarkdown(tt.source)
+			if err != nil {
+				t.Fatalf("RenderMarkdown(%q) returned error: %v", tt.source, err)
+			}
+
+			for _, w := range tt.want {
+				if !strings.Contains(html, w) {
+					t.Errorf("RenderMarkdown(%q) = %q, want it to contain %q", tt.source, html, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownTOC(t *testing.T) {
+	source := "# Intro\n\nText\n\n## Setup *fast*\n\n### Setup *fast*\n\n```\n# not a heading\n```\n"
+
+	_, toc, err := RenderMarkdown(source)
+	if err != nil {
+		t.Fatalf("RenderMarkdown returned error: %v", err)
+	}
+
+	want := []models.TOCEntry{
+		{Level: 1, Title: "Intro", Anchor: "intro"},
+		{Level: 2, Title: "Setup fast", Anchor: "setup-fast"},
+		{Level: 3, Title: "Setup fast", Anchor: "setup-fast-1"},
+	}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("RenderMarkdown table of contents = %+v, want %+v", toc, want)
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+// HasPermission checks if any of the roles grants the permission according to
+// the role definitions. The permission "*" grants every permission.
+func HasPermission(definitions map[string][]string, roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range definitions[role] {
+			if granted == permission || granted == models.PermAll {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// GrantsAllOf checks if the roles grant every permission of the other roles
+// according to the role definitions.
+func GrantsAllOf(definitions map[string][]string, roles, otherRoles []string) bool {
+	for _, role := range otherRoles {
+		for _, permission := range definitions[role] {
+			if !HasPermission(definitions, roles, permission) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// RequestHasPermission checks if the roles of the user grant the permission
+// and the API key of the request, if any, allows it. The roles of the user
+// are only looked up if the scopes allow the permission.
+func RequestHasPermission(r *http.Request, definitions map[string][]string, userRoles func(userID string) ([]string, error), userID, permission string) bool {
+	if !RequestScopesAllow(r, permission) {
+		return false
+	}
+
+	roles, err := userRoles(userID)
+	if err != nil {
+		return false
+	}
+
+	return HasPermission(definitions, roles, permission)
+}
+
+// RequestScopesAllow checks if the scopes of the API key of the request allow
+// the permission. Requests without an API key are not limited by scopes.
+func RequestScopesAllow(r *http.Request, permission string) bool {
+	auth, ok := GetAuthentication(r)
+	if !ok || !auth.APIKey {
+		return true
+	}
+
+	return ScopesAllow(auth.Scopes, permission)
+}
+
+// ScopesAllow checks if any of the scopes of an API key allows the
+// permission.
+func ScopesAllow(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		for _, allowed := range models.ScopePermissions[scope] {
+			if allowed == permission || allowed == models.PermAll {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ScopesAllowMethod checks if the scopes of an API key allow requests with the
+// method. Reading only needs any scope, everything else needs a scope which
+// allows writing.
+func ScopesAllowMethod(scopes []string, method string) bool {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+		return len(scopes) > 0
+	}
+
+	for _, scope := range scopes {
+		if scope == models.ScopePostsWrite || scope == models.ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+// testRoles are role definitions like the built-in ones.
+var testRoles = map[string][]string{
+	models.RoleUser:      {models.PermPostCreate, models.PermPostPublish, models.PermMediaUpload},
+	models.RoleEditor:    {models.PermPostCreate, models.PermPostPublish, models.PermPostEditAny, models.PermMediaDeleteAny},
+	models.RoleModerator: {models.PermPostCreate, models.PermUserBan},
+	models.RoleAdmin:     {models.PermAll},
+}
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{"granted", []string{models.RoleUser}, models.PermPostCreate, true},
+		{"not granted", []string{models.RoleUser}, models.PermPostEditAny, false},
+		{"granted by another role", []string{models.RoleUser, models.RoleEditor}, models.PermPostEditAny, true},
+		{"everything for *", []string{models.RoleAdmin}, models.PermAuditRead, true},
+		{"* itself for *", []string{models.RoleAdmin}, models.PermAll, true},
+		{"* not for other roles", []string{models.RoleEditor}, models.PermAll, false},
+		{"unknown role", []string{"ghost"}, models.PermPostCreate, false},
+		{"unknown role next to a known one", []string{"ghost", models.RoleUser}, models.PermPostCreate, true},
+		{"no roles", nil, models.PermPostCreate, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasPermission(testRoles, tt.roles, tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%v, %s) = %t, want %t", tt.roles, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesAllow(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     []string
+		permission string
+		want       bool
+	}{
+		{"read scope reads any post", []string{models.ScopePostsRead}, models.PermPostReadAny, true},
+		{"read scope can't create", []string{models.ScopePostsRead}, models.PermPostCreate, false},
+		{"write scope creates", []string{models.ScopePostsWrite}, models.PermPostCreate, true},
+		{"write scope can't publish", []string{models.ScopePostsWrite}, models.PermPostPublish, false},
+		{"publish scope publishes", []string{models.ScopePostsPublish}, models.PermPostPublish, true},
+		{"publish scope can't create", []string{models.ScopePostsPublish}, models.PermPostCreate, false},
+		{"write scope can't ban", []string{models.ScopePostsWrite}, models.PermUserBan, false},
+		{"admin scope allows everything", []string{models.ScopeAdmin}, models.PermUserBan, true},
+		{"combined scopes", []string{models.ScopePostsWrite, models.ScopePostsPublish}, models.PermPostPublish, true},
+		{"unknown scope", []string{"everything"}, models.PermPostReadAny, false},
+		{"no scopes", nil, models.PermPostReadAny, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopesAllow(tt.scopes, tt.permission); got != tt.want {
+				t.Errorf("ScopesAllow(%v, %s) = %t, want %t", tt.scopes, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesAllowMethod(t *testing.T) {
+	read := []string{models.ScopePostsRead}
+	write := []string{models.ScopePostsWrite}
+	publish := []string{models.ScopePostsPublish}
+	admin := []string{models.ScopeAdmin}
+
+	tests := []struct {
+		scopes []string
+		method string
+		want   bool
+	}{
+		{read, http.MethodGet, true},
+		{read, http.MethodHead, true},
+		{read, http.MethodOptions, true},
+		{read, http.MethodPost, false},
+		{read, http.MethodPatch, false},
+		{read, http.MethodPut, false},
+		{read, http.MethodDelete, false},
+		{publish, http.MethodGet, true},
+		{publish, http.MethodPatch, false},
+		{write, http.MethodGet, true},
+		{write, http.MethodPost, true},
+		{write, http.MethodPatch, true},
+		{write, http.MethodDelete, true},
+		{admin, http.MethodPut, true},
+		{nil, http.MethodGet, false},
+		{nil, http.MethodPost, false},
+	}
+
+	for _, tt := range tests {
+		if got := ScopesAllowMethod(tt.scopes, tt.method); got != tt.want {
+			t.Errorf("ScopesAllowMethod(%v, %s) = %t, want %t", tt.scopes, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestRequestHasPermission(t *testing.T) {
+	userRoles := func(userID string) ([]string, error) {
+		switch userID {
+		case "admin":
+			return []string{models.RoleAdmin}, nil
+		case "user":
+			return []string{models.RoleUser}, nil
+		}
+		return nil, errors.New("user not found")
+	}
+
+	tests := []struct {
+		name       string
+		auth       *Authentication
+		userID     string
+		permission string
+		want       bool
+	}{
+		{"session of an admin", &Authentication{UserID: "admin"}, "admin", models.PermUserBan, true},
+		{"session of a user", &Authentication{UserID: "user"}, "user", models.PermUserBan, false},
+		{"no authentication", nil, "admin", models.PermUserBan, true},
+		{"admin key narrower than the roles", &Authentication{UserID: "admin", APIKey: true, Scopes: []string{models.ScopePostsWrite}}, "admin", models.PermUserBan, false},
+		{"admin key within its scopes", &Authentication{UserID: "admin", APIKey: true, Scopes: []string{models.ScopePostsWrite}}, "admin", models.PermPostEditAny, true},
+		{"read key of an admin can't publish", &Authentication{UserID: "admin", APIKey: true, Scopes: []string{models.ScopePostsRead}}, "admin", models.PermPostPublish, false},
+		{"key broader than the roles", &Authentication{UserID: "user", APIKey: true, Scopes: []string{models.ScopeAdmin}}, "user", models.PermUserBan, false},
+		{"unknown user", &Authentication{UserID: "ghost"}, "ghost", models.PermPostCreate, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != nil {
+				r = WithAuthentication(r, *tt.auth)
+			}
+
+			if got := RequestHasPermission(r, testRoles, userRoles, tt.userID, tt.permission); got != tt.want {
+				t.Errorf("RequestHasPermission(%s, %s) = %t, want %t", tt.userID, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrantsAllOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		otherRoles []string
+		want       bool
+	}{
+		{"same role", []string{models.RoleModerator}, []string{models.RoleModerator}, true},
+		{"one permission missing", []string{models.RoleEditor}, []string{models.RoleUser}, false},
+		{"admin above everyone", []string{models.RoleAdmin}, []string{models.RoleEditor, models.RoleModerator}, true},
+		{"moderator below admin", []string{models.RoleModerator}, []string{models.RoleAdmin}, false},
+		{"moderator next to an editor", []string{models.RoleModerator}, []string{models.RoleEditor}, false},
+		{"roles combined", []string{models.RoleUser, models.RoleModerator}, []string{models.RoleUser}, true},
+		{"target without roles", []string{models.RoleUser}, nil, true},
+		{"unknown target role", nil, []string{"ghost"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GrantsAllOf(testRoles, tt.roles, tt.otherRoles); got != tt.want {
+				t.Errorf("GrantsAllOf(%v, %v) = %t, want %t", tt.roles, tt.otherRoles, got, tt.want)
+			}
+		})
+	}
+}
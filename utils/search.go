@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+// Weights of the searchable fields of a post. They match the weights of the
+// text index in the mongo repository.
+const (
+	SEARCH_TITLE_WEIGHT = 10
+	SEARCH_TEXT_WEIGHT  = 1
+)
+
+// SNIPPET_LENGTH is the approximate length of a search snippet in characters.
+const SNIPPET_LENGTH = 160
+
+// wordSpan describes the position of a word inside a text.
+type wordSpan struct {
+	start, end int
+	word       string
+}
+
+// splitWords splits a text into its lower case words together with their
+// byte positions. Everything except letters and numbers separates words.
+func splitWords(text string) []wordSpan {
+	spans := []wordSpan{}
+	start := -1
+
+	for i, char := range text {
+		isWordChar := unicode.IsLetter(char) || unicode.IsNumber(char)
+		if isWordChar && start < 0 {
+			start = i
+		}
+		if !isWordChar && start >= 0 {
+			spans = append(spans, wordSpan{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{start, len(text), strings.ToLower(text[start:])})
+	}
+
+	return spans
+}
+
+// SearchTerms splits a search query into its unique lower case terms.
+func SearchTerms(query string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, span := range splitWords(query) {
+		if !seen[span.word] {
+			seen[span.word] = true
+			terms = append(terms, span.word)
+		}
+	}
+	return terms
+}
+
+// countTerms counts how often any of the terms occurs as a word in the text.
+func countTerms(text string, terms map[string]bool) int {
+	count := 0
+	for _, span := range splitWords(text) {
+		if terms[span.word] {
+			count++
+		}
+	}
+	return count
+}
+
+// RankPosts is the pure go fallback for searching posts in repositories
+// without a text index. Posts are scored by their matching words with title
+// matches weighted above text matches. Posts without any match are dropped
+// and the rest is sorted by score, the best match first.
+func RankPosts(posts []*models.Post, query string) []*models.SearchResult {
+	terms := map[string]bool{}
+	for _, term := range SearchTerms(query) {
+		terms[term] = true
+	}
+
+	results := []*models.SearchResult{}
+	for _, post := range posts {
+		score := SEARCH_TITLE_WEIGHT*countTerms(post.Title, terms) +
+			SEARCH_TEXT_WEIGHT*countTerms(post.Text, terms)
+		if score == 0 {
+			continue
+		}
+
+		results = append(results, &models.SearchResult{
+			Post:  *post,
+			Score: float64(score),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+// Highlight creates a short HTML snippet of the text around the first match
+// of the search terms. The text is escaped and every matching word is
+// wrapped in a <mark> element.
+func Highlight(text string, terms []string) string {
+	termSet := map[string]bool{}
+	for _, term := range terms {
+		termSet[term] = true
+	}
+
+	spans := splitWords(text)
+
+	first := 0
+	for i, span := range spans {
+		if termSet[span.word] {
+			first = i
+			break
+		}
+	}
+
+	// start a few words in front of the first match
+	from := first - 5
+	if from < 0 {
+		from = 0
+	}
+
+	start := 0
+	if from > 0 {
+		start = spans[from].start
+	}
+
+	end := len(text)
+	if end-start > SNIPPET_LENGTH {
+		end = start
+		for _, span := range spans[from:] {
+			if span.end-start > SNIPPET_LENGTH {
+				break
+			}
+			end = span.end
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, span := range spans[from:] {
+		if span.end > end {
+			break
+		}
+		if !termSet[span.word] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:span.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[span.start:span.end]))
+		b.WriteString("</mark>")
+		pos = span.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Go", []string{"go"}},
+		{"  go   Testing ", []string{"go", "testing"}},
+		{"go, GO; go!", []string{"go"}},
+		{"crème-brûlée 2022", []string{"crème", "brûlée", "2022"}},
+		{"", []string{}},
+		{"?!", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := SearchTerms(tt.query)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRankPosts(t *testing.T) {
+	posts := []*models.Post{
+		{ID: "text-once", Title: "Bread", Text: "Unlike Go, bread needs patience."},
+		{ID: "title-once", Title: "Getting started with Go", Text: "Install the toolchain."},
+		{ID: "no-match", Title: "Sourdough", Text: "Feed the starter."},
+		{ID: "title-and-text", Title: "Testing in Go", Text: "Go runs table tests with go test."},
+		{ID: "text-many", Title: "Notes", Text: "go go go go go go go go go go go"},
+		{ID: "partial-word", Title: "Gopher", Text: "Google and golang are not the term."},
+		{ID: "title-once-later", Title: "Go modules", Text: "Versions."},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		ids    []string
+		scores []float64
+	}{
+		{
+			name:   "title matches rank above text matches",
+			query:  "go",
+			ids:    []string{"title-and-text", "text-many", "title-once", "title-once-later", "text-once"},
+			scores: []float64{12, 11, 10, 10, 1},
+		},
+		{
+			name:   "case and duplicate terms don't matter",
+			query:  "GO go",
+			ids:    []string{"title-and-text", "text-many", "title-once", "title-once-later", "text-once"},
+			scores: []float64{12, 11, 10, 10, 1},
+		},
+		{
+			name:   "every term counts",
+			query:  "bread patience",
+			ids:    []string{"text-once"},
+			scores: []float64{12},
+		},
+		{
+			name:   "no match",
+			query:  "rust",
+			ids:    []string{},
+			scores: []float64{},
+		},
+		{
+			name:   "empty query",
+			query:  "",
+			ids:    []string{},
+			scores: []float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := RankPosts(posts, tt.query)
+
+			ids := []string{}
+			scores := []float64{}
+			for _, result := range results {
+				ids = append(ids, result.ID)
+				scores = append(scores, result.Score)
+			}
+
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("RankPosts(%q) ranked %q, want %q", tt.query, ids, tt.ids)
+			}
+			if !reflect.DeepEqual(scores, tt.scores) {
+				t.Errorf("RankPosts(%q) scored %v, want %v", tt.query, scores, tt.scores)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "marks every match",
+			text:  "Go runs table tests with go test.",
+			terms: []string{"go", "test"},
+			want:  "<mark>Go</mark> runs table tests with <mark>go</mark> <mark>test</mark>.",
+		},
+		{
+			name:  "escapes the text",
+			text:  `<script>alert("go")</script> & go`,
+			terms: []string{"go"},
+			want:  `&lt;script&gt;alert(&#34;<mark>go</mark>&#34;)&lt;/script&gt; &amp; <mark>go</mark>`,
+		},
+		{
+			name:  "no match keeps the start",
+			text:  "Nothing to see here.",
+			terms: []string{"go"},
+			want:  "Nothing to see here.",
+		},
+		{
+			name:  "starts a few words before the first match",
+			text:  "one two three four five six seven eight nine ten eleven twelve go thirteen",
+			terms: []string{"go"},
+			want:  "…eight nine ten eleven twelve <mark>go</mark> thirteen",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Highlight(tt.text, tt.terms)
+			if got != tt.want {
+				t.Errorf("Highlight(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightLength(t *testing.T) {
+	text := ""
+	for i := 0; i < 100; i++ {
+		text += "word "
+	}
+	text += "match " + text
+
+	got := Highlight(text, []string{"match"})
+
+	// the snippet plus the two ellipses
+	if n := len([]rune(got)); n > SNIPPET_LENGTH+len("<mark></mark>")+2 {
+		t.Errorf("Highlight returned %d characters, want at most about %d", n, SNIPPET_LENGTH)
+	}
+	if got[:len("…")] != "…" || got[len(got)-len("…"):] != "…" {
+		t.Errorf("Highlight(%q) is not cut on both ends", got)
+	}
+}
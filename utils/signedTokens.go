@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// ErrInvalidToken is returned for tokens which are malformed, expired or made
+// for another purpose.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// CreateSignedToken creates a token signed with the secret. The purpose keeps
+// tokens of one flow from being used in another, the id allows to use the
+// token only once.
+// Returns the token and an error if any occurred.
+func CreateSignedToken(secret []byte, purpose, subject, id string, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Audience:  purpose,
+		Subject:   subject,
+		Id:        id,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+
+	return token.SignedString(secret)
+}
+
+// ParseSignedToken checks the signature, expiry and purpose of a token created
+// by CreateSignedToken.
+// Returns the claims of the token and an error if any occurred.
+func ParseSignedToken(secret []byte, purpose, tokenString string) (*jwt.StandardClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := token.Claims.(*jwt.StandardClaims)
+	if !claims.VerifyAudience(purpose, true) || claims.Subject == "" || claims.Id == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SLUG_MAX_LENGTH is the maximum length of a generated slug.
+const SLUG_MAX_LENGTH = 60
+
+// Slugify creates a URL-safe slug from the given text.
+// Accents are removed, everything except letters and numbers gets replaced by
+// single hyphens and the result is lower case.
+func Slugify(text string) string {
+	var b strings.Builder
+	hyphen := false
+
+	for _, char := range norm.NFKD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, char):
+			// drop the accents split off by the normalization
+		case char < unicode.MaxASCII && (unicode.IsLetter(char) || unicode.IsNumber(char)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(unicode.ToLower(char))
+		default:
+			hyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > SLUG_MAX_LENGTH {
+		slug = strings.TrimRight(slug[:SLUG_MAX_LENGTH], "-")
+	}
+	if slug == "" {
+		slug = "post"
+	}
+
+	return slug
+}
+
+// UniqueSlug creates a slug from the title which is not taken yet. Taken
+// slugs get the first free numeric suffix, starting with "-2".
+// Returns the slug and an error if checking a slug failed.
+func UniqueSlug(title string, taken func(slug string) (bool, error)) (string, error) {
+	base := Slugify(title)
+
+	for i := 1; ; i++ {
+		slug := base
+		if i > 1 {
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		isTaken, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !isTaken {
+			return slug, nil
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain title", "Hello World", "hello-world"},
+		{"accents", "Crème brûlée à la mode", "creme-brulee-a-la-mode"},
+		{"punctuation collapses", "Go: the --good-- parts!!", "go-the-good-parts"},
+		{"leading and trailing separators", "  ...Hello...  ", "hello"},
+		{"numbers", "Top 10 tips for 2022", "top-10-tips-for-2022"},
+		{"non latin letters are dropped", "日本語 guide", "guide"},
+		{"nothing left", "!!!", "post"},
+		{"empty", "", "post"},
+		{"truncated without trailing hyphen", strings.Repeat("a", 59) + " bcd", strings.Repeat("a", 59)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Slugify(tt.text)
+			if got != tt.want {
+				t.Errorf("Slugify(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if len(got) > SLUG_MAX_LENGTH {
+				t.Errorf("Slugify(%q) is %d characters long", tt.text, len(got))
+			}
+		})
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		taken []string
+		want  string
+	}{
+		{"free", "Hello World", nil, "hello-world"},
+		{"taken once", "Hello World", []string{"hello-world"}, "hello-world-2"},
+		{"suffixes taken", "Hello World", []string{"hello-world", "hello-world-2", "hello-world-3"}, "hello-world-4"},
+		{"gap in suffixes", "Hello World", []string{"hello-world", "hello-world-3"}, "hello-world-2"},
+		{"other slugs don't collide", "Hello World", []string{"hello", "world", "hello-world-2"}, "hello-world"},
+		{"titles with the same slug collide", "Hello, World!", []string{"hello-world"}, "hello-world-2"},
+		{"fallback slug", "???", []string{"post"}, "post-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := map[string]bool{}
+			for _, slug := range tt.taken {
+				taken[slug] = true
+			}
+
+			got, err := UniqueSlug(tt.title, func(slug string) (bool, error) {
+				return taken[slug], nil
+			})
+			if err != nil {
+				t.Fatalf("UniqueSlug(%q) returned error: %v", tt.title, err)
+			}
+			if got != tt.want {
+				t.Errorf("UniqueSlug(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueSlugError(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+
+	_, err := UniqueSlug("Hello World", func(slug string) (bool, error) {
+		return false, errLookup
+	})
+	if err != errLookup {
+		t.Errorf("UniqueSlug returned error %v, want %v", err, errLookup)
+	}
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+// maxDiffEdits bounds the number of inserted and deleted lines the diff
+// searches for. Texts differing in more lines are diffed as a replaced block,
+// so a diff takes time and memory linear in the number of lines.
+const maxDiffEdits = 1000
+
+// DiffLines creates a line based diff between the texts a and b with the
+// fewest inserted and deleted lines.
+func DiffLines(a, b string) []models.DiffLine {
+	oldLines := strings.Split(a, "\n")
+	newLines := strings.Split(b, "\n")
+
+	// the common head and tail need no search
+	head := 0
+	for head < len(oldLines) && head < len(newLines) && oldLines[head] == newLines[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(oldLines)-head && tail < len(newLines)-head &&
+		oldLines[len(oldLines)-1-tail] == newLines[len(newLines)-1-tail] {
+		tail++
+	}
+
+	diff := []models.DiffLine{}
+	for _, line := range oldLines[:head] {
+		diff = append(diff, models.DiffLine{Op: "equal", Text: line})
+	}
+	diff = append(diff, diffEdits(oldLines[head:len(oldLines)-tail], newLines[head:len(newLines)-tail])...)
+	for _, line := range oldLines[len(oldLines)-tail:] {
+		diff = append(diff, models.DiffLine{Op: "equal", Text: line})
+	}
+
+	return diff
+}
+
+// diffEdits finds the shortest edit script between the lines with the greedy
+// algorithm of Myers. Lines needing more than maxDiffEdits edits are replaced
+// as a whole.
+func diffEdits(oldLines, newLines []string) []models.DiffLine {
+	a, b := internLines(oldLines, newLines)
+	n, m := len(a), len(b)
+
+	// trace[d][k+d] holds the furthest x reached on the diagonal k = x - y
+	// with d edits
+	trace := [][]int{}
+	for d := 0; d <= n+m && d <= maxDiffEdits; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if d > 0 {
+				prev := trace[d-1]
+				if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+					x = prev[k+1+d-1]
+				} else {
+					x = prev[k-1+d-1] + 1
+				}
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+
+			if x >= n && y >= m {
+				return backtrackEdits(append(trace, v), oldLines, newLines)
+			}
+		}
+		trace = append(trace, v)
+	}
+
+	diff := []models.DiffLine{}
+	for _, line := range oldLines {
+		diff = append(diff, models.DiffLine{Op: "delete", Text: line})
+	}
+	for _, line := range newLines {
+		diff = append(diff, models.DiffLine{Op: "insert", Text: line})
+	}
+	return diff
+}
+
+// backtrackEdits follows the trace of diffEdits back from the end of both
+// texts and returns the edits on the way.
+func backtrackEdits(trace [][]int, oldLines, newLines []string) []models.DiffLine {
+	reversed := []models.DiffLine{}
+	x, y := len(oldLines), len(newLines)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prev := trace[d-1]
+			prevK := k - 1
+			if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+				prevK = k + 1
+			}
+			prevX = prev[prevK+d-1]
+			prevY = prevX - prevK
+		}
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, models.DiffLine{Op: "equal", Text: oldLines[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			reversed = append(reversed, models.DiffLine{Op: "insert", Text: newLines[y-1]})
+		} else {
+			reversed = append(reversed, models.DiffLine{Op: "delete", Text: oldLines[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+
+	diff := make([]models.DiffLine, len(reversed))
+	for i, line := range reversed {
+		diff[len(reversed)-1-i] = line
+	}
+	return diff
+}
+
+// internLines numbers the distinct lines of both texts, so the search
+// compares numbers instead of strings.
+func internLines(oldLines, newLines []string) ([]int, []int) {
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		numbers := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			numbers[i] = id
+		}
+		return numbers
+	}
+
+	return intern(oldLines), intern(newLines)
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/schattenbrot/mini-blog-api/models"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\nc", "a\nb\nc", " a  b  c"},
+		{"insert", "a\nc", "a\nb\nc", " a +b  c"},
+		{"insert at the start", "b\nc", "a\nb\nc", "+a  b  c"},
+		{"insert at the end", "a\nb", "a\nb\nc", " a  b +c"},
+		{"delete", "a\nb\nc", "a\nc", " a -b  c"},
+		{"delete at the start", "a\nb\nc", "b\nc", "-a  b  c"},
+		{"delete at the end", "a\nb\nc", "a\nb", " a  b -c"},
+		{"replace", "a\nb\nc", "a\nx\nc", " a -b +x  c"},
+		{"moved line", "a\nb\nc", "b\nc\na", "-a  b  c +a"},
+		{"from empty", "", "a", "- +a"},
+		{"both empty", "", "", " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffLines(tt.a, tt.b)
+			if got := formatDiff(diff); got != tt.want {
+				t.Errorf("DiffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+			checkDiff(t, diff, tt.a, tt.b)
+		})
+	}
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		lines := make([]string, random.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(4)))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomText(), randomText()
+
+		diff := DiffLines(a, b)
+		checkDiff(t, diff, a, b)
+
+		want := len(strings.Split(a, "\n")) + len(strings.Split(b, "\n")) - 2*longestCommonLines(a, b)
+		if got := countEdits(diff); got != want {
+			t.Errorf("DiffLines(%q, %q) has %d edits, want %d", a, b, got, want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	numbered := func(prefix string, count int) []string {
+		lines := make([]string, count)
+		for i := range lines {
+			lines[i] = prefix + strconv.Itoa(i)
+		}
+		return lines
+	}
+
+	t.Run("few edits", func(t *testing.T) {
+		oldLines := numbered("line ", 100000)
+		newLines := append([]string{}, oldLines...)
+		newLines[10] = "changed"
+		newLines[50000] = "changed"
+		newLines = append(newLines[:90000], newLines[90001:]...)
+
+		a, b := strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")
+		diff := DiffLines(a, b)
+		checkDiff(t, diff, a, b)
+		if got := countEdits(diff); got != 5 {
+			t.Errorf("DiffLines has %d edits, want 5", got)
+		}
+	})
+
+	t.Run("completely different", func(t *testing.T) {
+		a := strings.Join(numbered("old ", 100000), "\n")
+		b := strings.Join(numbered("new ", 100000), "\n")
+
+		diff := DiffLines(a, b)
+		checkDiff(t, diff, a, b)
+		if got := countEdits(diff); got != 200000 {
+			t.Errorf("DiffLines has %d edits, want 200000", got)
+		}
+	})
+
+	t.Run("beyond the edit bound", func(t *testing.T) {
+		oldLines := numbered("line ", 10000)
+		newLines := append([]string{}, oldLines...)
+		for i := 0; i < len(newLines); i += 5 {
+			newLines[i] = "changed"
+		}
+
+		// the lines between the first and last change are replaced
+		a, b := strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")
+		diff := DiffLines(a, b)
+		checkDiff(t, diff, a, b)
+		if got := countEdits(diff); got != 2*9996 {
+			t.Errorf("DiffLines has %d edits, want %d", got, 2*9996)
+		}
+	})
+}
+
+// formatDiff writes each line of the diff with its operation as prefix.
+func formatDiff(diff []models.DiffLine) string {
+	prefixes := map[string]string{"equal": " ", "insert": "+", "delete": "-"}
+
+	lines := []string{}
+	for _, line := range diff {
+		lines = append(lines, prefixes[line.Op]+line.Text)
+	}
+	return strings.Join(lines, " ")
+}
+
+// checkDiff checks that the diff turns a into b.
+func checkDiff(t *testing.T, diff []models.DiffLine, a, b string) {
+	t.Helper()
+
+	oldLines, newLines := []string{}, []string{}
+	for _, line := range diff {
+		switch line.Op {
+		case "equal":
+			oldLines = append(oldLines, line.Text)
+			newLines = append(newLines, line.Text)
+		case "delete":
+			oldLines = append(oldLines, line.Text)
+		case "insert":
+			newLines = append(newLines, line.Text)
+		default:
+			t.Fatalf("diff contains unknown operation %q", line.Op)
+		}
+	}
+
+	if strings.Join(oldLines, "\n") != a || strings.Join(newLines, "\n") != b {
+		t.Errorf("diff does not turn %.40q into %.40q", a, b)
+	}
+}
+
+// countEdits counts the inserted and deleted lines of the diff.
+func countEdits(diff []models.DiffLine) int {
+	edits := 0
+	for _, line := range diff {
+		if line.Op != "equal" {
+			edits++
+		}
+	}
+	return edits
+}
+
+// longestCommonLines returns the length of the longest common subsequence of
+// the lines of a and b.
+func longestCommonLines(a, b string) int {
+	oldLines, newLines := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			switch {
+			case oldLines[i] == newLines[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
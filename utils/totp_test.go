@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 secret "12345678901234567890" of the RFC 6238
+// test vectors in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// the last six digits of the eight digit SHA1 vectors of RFC 6238
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step := TOTPStep(time.Unix(tt.unix, 0))
+
+		code, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode at %d returned error: %v", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+
+		lower, err := TOTPCode(strings.ToLower(rfc6238Secret), step)
+		if err != nil || lower != tt.code {
+			t.Errorf("TOTPCode with lower case secret at %d = %s, %v, want %s", tt.unix, lower, err, tt.code)
+		}
+	}
+}
+
+func TestTOTPCodeMalformedSecret(t *testing.T) {
+	_, err := TOTPCode("not base32!", 1)
+	if err == nil {
+		t.Error("TOTPCode accepted a malformed secret")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	codeAt := func(step int64) string {
+		code, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("TOTPCode returned error: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name  string
+		code  string
+		valid bool
+		step  int64
+	}{
+		{"current step", codeAt(current), true, current},
+		{"previous step within skew", codeAt(current - 1), true, current - 1},
+		{"next step within skew", codeAt(current + 1), true, current + 1},
+		{"two steps behind", codeAt(current - 2), false, 0},
+		{"two steps ahead", codeAt(current + 2), false, 0},
+		{"wrong code", "000000", false, 0},
+		{"empty code", "", false, 0},
+		{"code with too many digits", codeAt(current) + "0", false, 0},
+		{"code with too few digits", codeAt(current)[1:], false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, valid := VerifyTOTP(rfc6238Secret, tt.code, now)
+			if valid != tt.valid || step != tt.step {
+				t.Errorf("VerifyTOTP(%q) = %d, %t, want %d, %t", tt.code, step, valid, tt.step, tt.valid)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPMalformedSecret(t *testing.T) {
+	step, valid := VerifyTOTP("not base32!", "123456", time.Now())
+	if valid || step != 0 {
+		t.Errorf("VerifyTOTP with malformed secret = %d, %t, want 0, false", step, valid)
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret returned error: %v", err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("NewTOTPSecret returned a secret which is not base32: %v", err)
+	}
+	if len(key) != TOTP_SECRET_SIZE {
+		t.Errorf("NewTOTPSecret returned %d bytes, want %d", len(key), TOTP_SECRET_SIZE)
+	}
+
+	other, err := NewTOTPSecret()
+	if err != nil || other == secret {
+		t.Errorf("NewTOTPSecret returned the same secret twice")
+	}
+
+	_, err = TOTPCode(secret, 1)
+	if err != nil {
+		t.Errorf("TOTPCode can't use a new secret: %v", err)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Mini Blog", "user@example.com", rfc6238Secret)
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("TOTPURI returned an invalid URI: %v", err)
+	}
+
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("TOTPURI = %s, want an otpauth://totp/ URI", uri)
+	}
+	if parsed.Path != "/Mini Blog:user@example.com" {
+		t.Errorf("TOTPURI label = %q, want %q", parsed.Path, "/Mini Blog:user@example.com")
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "Mini Blog",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("TOTPURI %s = %q, want %q", key, query.Get(key), value)
+		}
+	}
+}
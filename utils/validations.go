@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var tagRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+var objectIDRegex = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// RegisterValidations registers the custom validation tags of the app.
+//
+// Registered tags:
+//   - tag: lower case letters, numbers and single hyphens, 2 to 30 characters
+//   - role: one of the given role definitions
+//   - mongodb: a hex encoded mongodb ObjectID
+//   - maxlines=N: a text of at most N lines
+func RegisterValidations(v *validator.Validate, roles map[string][]string) {
+	v.RegisterValidation("tag", isValidTag)
+	v.RegisterValidation("mongodb", isValidObjectID)
+	v.RegisterValidation("maxlines", hasMaxLines)
+	v.RegisterValidation("role", func(fl validator.FieldLevel) bool {
+		_, ok := roles[fl.Field().String()]
+		return ok
+	})
+}
+
+// isValidTag checks if the field is a valid tag or category.
+func isValidTag(fl validator.FieldLevel) bool {
+	tag := fl.Field().String()
+	if len(tag) < 2 || len(tag) > 30 {
+		return false
+	}
+	return tagRegex.MatchString(tag)
+}
+
+// isValidObjectID checks if the field is a hex encoded mongodb ObjectID.
+func isValidObjectID(fl validator.FieldLevel) bool {
+	return objectIDRegex.MatchString(fl.Field().String())
+}
+
+// hasMaxLines checks if the text of the field has at most as many lines as the
+// parameter of the tag.
+func hasMaxLines(fl validator.FieldLevel) bool {
+	max, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		return false
+	}
+	return strings.Count(fl.Field().String(), "\n") < max
+}
+
+// NormalizeTag lower cases a tag and replaces whitespace with hyphens.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// NormalizeTags normalizes all tags and removes empty and duplicate entries.
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestMaxLinesValidation(t *testing.T) {
+	v := validator.New()
+	RegisterValidations(v, nil)
+
+	tests := []struct {
+		name  string
+		text  string
+		valid bool
+	}{
+		{"empty", "", true},
+		{"single line", "hello", true},
+		{"at the limit", strings.Repeat("line\n", 2) + "line", true},
+		{"trailing newline at the limit", strings.Repeat("line\n", 2), true},
+		{"above the limit", strings.Repeat("line\n", 3) + "line", false},
+		{"only newlines", strings.Repeat("\n", 10), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Var(tt.text, "maxlines=3")
+			if (err == nil) != tt.valid {
+				t.Errorf("maxlines=3 of %q = %v, want valid %t", tt.text, err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database"
+)
+
+// RunTrashPurge permanently deletes all posts and users which are in the
+// trash for longer than the configured retention. It purges once right away
+// and then in the configured interval until the context is done.
+func RunTrashPurge(ctx context.Context, app *config.AppConfig, db database.DatabaseRepo) {
+	ticker := time.NewTicker(app.Config.Trash.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purgeTrash(app, db)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash runs a single purge and logs its outcome.
+func purgeTrash(app *config.AppConfig, db database.DatabaseRepo) {
+	purged, err := db.PurgeDeleted(time.Now().Add(-app.Config.Trash.Retention))
+	if err != nil {
+		app.Logger.Println("could not purge the trash:", err)
+		return
+	}
+
+	if purged > 0 {
+		app.Logger.Printf("purged %d documents from the trash", purged)
+	}
+}
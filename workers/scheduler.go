@@ -0,0 +1,83 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/schattenbrot/mini-blog-api/config"
+	"github.com/schattenbrot/mini-blog-api/database"
+	"github.com/schattenbrot/mini-blog-api/utils"
+)
+
+// schedulerLease is the name of the lease which makes sure that only one
+// instance of the api publishes the scheduled posts.
+const schedulerLease = "scheduler"
+
+// RunScheduler publishes the scheduled posts whose publish time has arrived.
+// It checks once right away and then in the configured interval until the
+// context is done. When several instances of the api run, only the holder of
+// the scheduler lease publishes posts.
+func RunScheduler(ctx context.Context, app *config.AppConfig, db database.DatabaseRepo) {
+	holder, err := leaseHolder()
+	if err != nil {
+		app.Logger.Println("could not start the scheduler:", err)
+		return
+	}
+	defer db.ReleaseLease(schedulerLease, holder)
+
+	interval := app.Config.Scheduler.Interval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		publishScheduledPosts(app, db, holder, 2*interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishScheduledPosts publishes the due posts if the holder gets the
+// scheduler lease and logs the outcome.
+func publishScheduledPosts(app *config.AppConfig, db database.DatabaseRepo, holder string, ttl time.Duration) {
+	acquired, err := db.AcquireLease(schedulerLease, holder, ttl)
+	if err != nil {
+		app.Logger.Println("could not acquire the scheduler lease:", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	published, err := db.PublishScheduledPosts(time.Now())
+	if err != nil {
+		app.Logger.Println("could not publish the scheduled posts:", err)
+		return
+	}
+
+	if published > 0 {
+		app.Logger.Printf("published %d scheduled posts", published)
+	}
+}
+
+// leaseHolder creates a name which identifies this instance of the api as
+// the holder of a lease.
+// Returns the name and an error if any occurred.
+func leaseHolder() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	token, err := utils.RandomToken(8)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s", hostname, token), nil
+}